@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// Input struct for assigning a todo to a member of its list
+type AssigneeInput struct {
+	Email string `json:"email"`
+	validator.Validator
+}
+
+// Response struct for returning the open todos assigned to the user
+type AssignedResponse struct {
+	Todos []TodoResponse `json:"todos"`
+}
+
+// assign a todo to a member of its list, found by their email
+func (app *application) todoAssign(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	var input AssigneeInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	assignee, err := app.users.GetByEmail(input.Email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			input.AddFieldError("email", "No user is registered with this email")
+			app.failedValidation(w, r, &input.Validator)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	err = app.todos.Assign(userID, id, assignee.Uuid)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w, r)
+		case errors.Is(err, models.ErrNotMember):
+			input.AddFieldError("email", "This user is not a member of the list of the todo")
+			app.failedValidation(w, r, &input.Validator)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.setFlash(r.Context(), "Todo has been assigned.")
+	app.writeTodo(w, r, userID, id)
+}
+
+// remove the assignee of a todo
+func (app *application) todoUnassign(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	err := app.todos.Unassign(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.setFlash(r.Context(), "Todo has been unassigned.")
+	app.writeTodo(w, r, userID, id)
+}
+
+// list the open todos assigned to the user, in all the lists they are a
+// member of
+func (app *application) todoAssigned(w http.ResponseWriter, r *http.Request) {
+	todos, err := app.todos.Assigned(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, AssignedResponse{Todos: newTodoResponses(todos)})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// canWorkOn reports whether the user can change the status of t and track
+// time on it: the editors of its list can, and so can its assignee whatever
+// their role
+func (app *application) canWorkOn(r *http.Request, t *models.Todo) bool {
+	return models.RoleAtLeast(app.listRole(r), models.RoleEditor) || t.AssigneeID == app.authenticatedUserID(r)
+}
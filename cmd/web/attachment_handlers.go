@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/storage"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// the attachment routes may read or write a whole file, so they get
+// longer deadlines than the server timeouts
+const attachmentTransferTimeout = 2 * time.Minute
+
+// content types accepted for attachments, as sniffed from the content itself
+var attachmentContentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+}
+
+// Input struct for uploading an attachment, filled from the multipart form
+type attachmentUpload struct {
+	Filename    string
+	ContentType string
+	Empty       bool
+	validator.Validator
+}
+
+// Response struct for returning attachment metadata
+type AttachmentResponse struct {
+	ID          string    `json:"id"`
+	TodoID      string    `json:"todo_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"sha256"`
+	Created     time.Time `json:"created"`
+}
+
+// Response struct for returning the attachments of a todo
+type AttachmentListResponse struct {
+	Attachments []AttachmentResponse `json:"attachments"`
+}
+
+// newAttachmentResponse converts an attachment model into its JSON representation
+func newAttachmentResponse(a *models.Attachment) AttachmentResponse {
+	return AttachmentResponse{
+		ID:          a.ID,
+		TodoID:      a.TodoID,
+		Filename:    a.Filename,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		Checksum:    a.Checksum,
+		Created:     a.Created,
+	}
+}
+
+// list the attachments of a todo
+func (app *application) attachmentList(w http.ResponseWriter, r *http.Request) {
+	todoID := readIDParam(r)
+	if todoID == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	attachments, err := app.attachments.All(app.authenticatedUserID(r), todoID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	responses := make([]AttachmentResponse, 0, len(attachments))
+	for _, a := range attachments {
+		responses = append(responses, newAttachmentResponse(a))
+	}
+
+	err = encodeJSON(w, http.StatusOK, AttachmentListResponse{Attachments: responses})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// upload a file to a todo, as the "file" field of a multipart/form-data body
+func (app *application) attachmentCreate(w http.ResponseWriter, r *http.Request) {
+	todoID := readIDParam(r)
+	if todoID == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	// check the todo first, so that nothing is stored for a missing todo
+	_, err := app.todos.Get(userID, todoID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	http.NewResponseController(w).SetReadDeadline(time.Now().Add(attachmentTransferTimeout))
+	// leave room for the multipart headers around the file
+	r.Body = http.MaxBytesReader(w, r.Body, app.maxAttachmentSize+1_048_576)
+
+	filename, file, err := readFilePart(r)
+	if err != nil {
+		app.uploadError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	// the content type is sniffed from the first bytes of the file,
+	// the one announced by the client is ignored
+	content := bufio.NewReaderSize(file, 512)
+	head, err := content.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		app.uploadError(w, r, err)
+		return
+	}
+
+	input := attachmentUpload{
+		Filename:    filename,
+		ContentType: strings.TrimSpace(strings.Split(http.DetectContentType(head), ";")[0]),
+		Empty:       len(head) == 0,
+	}
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	staged, err := app.storage.Stage(content, app.maxAttachmentSize)
+	if err != nil {
+		app.uploadError(w, r, err)
+		return
+	}
+	defer staged.Discard()
+
+	attachment := &models.Attachment{
+		ID:          uuid.New().String(),
+		TodoID:      todoID,
+		Filename:    input.Filename,
+		ContentType: input.ContentType,
+		Size:        staged.Size(),
+		Checksum:    staged.Key(),
+	}
+
+	// the content is only stored along with its attachment
+	err = app.attachments.Insert(userID, attachment, staged.Commit)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	attachment, err = app.attachments.Get(userID, todoID, attachment.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, http.StatusCreated, newAttachmentResponse(attachment))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// download the content of an attachment. Images are displayed inline,
+// other files are downloaded.
+func (app *application) attachmentDownload(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := app.readAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	file, err := app.storage.Open(attachment.Checksum)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	// the whole content is read before anything is sent,
+	// so that a corrupted file is never served
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(attachmentTransferTimeout))
+	content, err := io.ReadAll(file)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.ContentType, "image/") {
+		disposition = "inline"
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("ETag", `"`+attachment.Checksum+`"`)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	http.ServeContent(w, r, "", attachment.Created, bytes.NewReader(content))
+}
+
+// delete an attachment, its content is removed from the store unless
+// another attachment shares it
+func (app *application) attachmentDelete(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := app.readAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.attachments.Delete(app.authenticatedUserID(r), attachment.TodoID, attachment.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.removeContents(attachment.Checksum)
+
+	err = encodeJSON(w, http.StatusOK, newAttachmentResponse(attachment))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// readAttachment returns the attachment of the route, or sends
+// a 404 Not Found response and returns false
+func (app *application) readAttachment(w http.ResponseWriter, r *http.Request) (*models.Attachment, bool) {
+	todoID := readIDParam(r)
+	id := readUUIDParam(r, "attachmentId")
+	if todoID == "" || id == "" {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	attachment, err := app.attachments.Get(app.authenticatedUserID(r), todoID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return attachment, true
+}
+
+// readFilePart returns the name and content of the "file" field of a
+// multipart/form-data request. The body is streamed rather than parsed up
+// front, unless the form has already been parsed for its CSRF token.
+func readFilePart(r *http.Request) (string, io.ReadCloser, error) {
+	if r.MultipartForm != nil {
+		headers := r.MultipartForm.File["file"]
+		if len(headers) == 0 {
+			return "", nil, errors.New(`body must contain a "file" field`)
+		}
+		file, err := headers[0].Open()
+		return headers[0].Filename, file, err
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return "", nil, errors.New("body must be a multipart/form-data form")
+	}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return "", nil, errors.New(`body must contain a "file" field`)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if part.FormName() == "file" {
+			return part.FileName(), part, nil
+		}
+		part.Close()
+	}
+}
+
+// uploadError sends the response matching an error met while reading
+// an uploaded file
+func (app *application) uploadError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesError *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, storage.ErrTooLarge), errors.As(err, &maxBytesError):
+		app.errorResponse(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("The file cannot be larger than %d bytes", app.maxAttachmentSize))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		app.badRequest(w, r, errors.New("body contains a truncated multipart form"))
+	case strings.HasPrefix(err.Error(), "body "), strings.HasPrefix(err.Error(), "multipart: "):
+		app.badRequest(w, r, err)
+	default:
+		app.serverError(w, r, err)
+	}
+}
+
+// removeContents deletes the stored contents which no attachment uses
+// anymore. Failures are only logged, as the attachments themselves are gone.
+func (app *application) removeContents(checksums ...string) {
+	for _, checksum := range checksums {
+		err := app.attachments.Release(checksum, func() error {
+			return app.storage.Delete(checksum)
+		})
+		if err != nil {
+			app.errorLog.Printf("Error while removing the content %s: %s", checksum, err)
+		}
+	}
+}
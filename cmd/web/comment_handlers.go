@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// maximum length of a comment, in characters
+const maxCommentChars = 2000
+
+// Input struct for adding and editing comments
+type CommentInput struct {
+	Body string `json:"body"`
+	validator.Validator
+}
+
+// Response struct for returning a comment
+type CommentResponse struct {
+	ID         string    `json:"id"`
+	TodoID     string    `json:"todo_id"`
+	AuthorID   string    `json:"author_id"`
+	AuthorName string    `json:"author_name"`
+	Body       string    `json:"body"`
+	Created    time.Time `json:"created"`
+	// null for comments never edited
+	EditedAt *time.Time `json:"edited_at"`
+}
+
+// Response struct for returning the comments on a todo, oldest first
+type CommentListResponse struct {
+	Comments []CommentResponse `json:"comments"`
+}
+
+// newCommentResponse converts a comment model into its JSON representation
+func newCommentResponse(c *models.Comment) CommentResponse {
+	return CommentResponse{
+		ID:         c.ID,
+		TodoID:     c.TodoID,
+		AuthorID:   c.UserID,
+		AuthorName: c.AuthorName,
+		Body:       c.Body,
+		Created:    c.Created,
+		EditedAt:   c.EditedAt,
+	}
+}
+
+// list the comments on a todo
+func (app *application) commentList(w http.ResponseWriter, r *http.Request) {
+	todoID := readIDParam(r)
+	if todoID == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	comments, err := app.comments.All(app.authenticatedUserID(r), todoID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	responses := make([]CommentResponse, 0, len(comments))
+	for _, c := range comments {
+		responses = append(responses, newCommentResponse(c))
+	}
+
+	err = encodeJSON(w, http.StatusOK, CommentListResponse{Comments: responses})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// add a comment to a todo
+func (app *application) commentCreate(w http.ResponseWriter, r *http.Request) {
+	todoID := readIDParam(r)
+	if todoID == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	var input CommentInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	comment := &models.Comment{
+		ID:     uuid.New().String(),
+		TodoID: todoID,
+		Body:   input.Body,
+	}
+
+	err = app.comments.Insert(userID, comment)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.writeComment(w, r, http.StatusCreated, userID, todoID, comment.ID)
+}
+
+// edit a comment, only its author can
+func (app *application) commentUpdate(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.readOwnComment(w, r)
+	if !ok {
+		return
+	}
+
+	var input CommentInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	err = app.comments.Update(userID, comment.TodoID, comment.ID, input.Body)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.writeComment(w, r, http.StatusOK, userID, comment.TodoID, comment.ID)
+}
+
+// delete a comment, only its author can
+func (app *application) commentDelete(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.readOwnComment(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.comments.Delete(app.authenticatedUserID(r), comment.TodoID, comment.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, newCommentResponse(comment))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// readOwnComment returns the comment of the route if the user wrote it.
+// Otherwise it sends a 404 Not Found or 403 Forbidden response and
+// returns false.
+func (app *application) readOwnComment(w http.ResponseWriter, r *http.Request) (*models.Comment, bool) {
+	todoID := readIDParam(r)
+	id := readUUIDParam(r, "commentId")
+	if todoID == "" || id == "" {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	userID := app.authenticatedUserID(r)
+	comment, err := app.comments.Get(userID, todoID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if comment.UserID != userID {
+		app.forbidden(w, r, "Only the author of a comment can change it")
+		return nil, false
+	}
+
+	return comment, true
+}
+
+// writeComment sends a comment as read back from the database
+func (app *application) writeComment(w http.ResponseWriter, r *http.Request, status int, userID, todoID, id string) {
+	comment, err := app.comments.Get(userID, todoID, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, status, newCommentResponse(comment))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
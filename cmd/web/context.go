@@ -3,3 +3,9 @@ package main
 type contextKey string
 
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+const authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+
+const requestIDContextKey = contextKey("requestID")
+
+const listRoleContextKey = contextKey("listRole")
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// Response struct for returning the todos a todo waits for, and those
+// waiting for it
+type DependenciesResponse struct {
+	Blockers []TodoResponse `json:"blockers"`
+	Blocking []TodoResponse `json:"blocking"`
+}
+
+// Response struct for returning the open todos in the order they can be
+// done
+type NextResponse struct {
+	// todos waiting for no open todo
+	Ready []TodoResponse `json:"ready"`
+	// todos waiting for open todos, each after the todos it waits for
+	Waiting []TodoResponse `json:"waiting"`
+}
+
+// list the todos a todo waits for, and those waiting for it
+func (app *application) dependencyList(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	blockers, blocking, err := app.todos.Dependencies(app.authenticatedUserID(r), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	response := DependenciesResponse{
+		Blockers: newTodoResponses(blockers),
+		Blocking: newTodoResponses(blocking),
+	}
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// make a todo wait for another one, which can be in any list the user is a
+// member of. The todo cannot be completed while its blocker is open.
+func (app *application) dependencyAdd(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	blockerID := readUUIDParam(r, "blockerId")
+	if id == "" || blockerID == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	err := app.todos.Block(userID, id, blockerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w, r)
+		case errors.Is(err, models.ErrCycle):
+			app.errorResponse(w, r, http.StatusConflict, "The blocker already waits for this todo, the dependency would create a cycle")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.setFlash(r.Context(), "Dependency has been added.")
+	app.writeTodo(w, r, userID, id)
+}
+
+// stop a todo from waiting for another one
+func (app *application) dependencyRemove(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	blockerID := readUUIDParam(r, "blockerId")
+	if id == "" || blockerID == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	err := app.todos.Unblock(userID, id, blockerID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.setFlash(r.Context(), "Dependency has been removed.")
+	app.writeTodo(w, r, userID, id)
+}
+
+// list the open todos of the user in the order they can be done, the
+// todos waiting for no open todo first. ?list= limits them to one list.
+func (app *application) todoNext(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+	listID := readListParam(r.URL.Query(), &v)
+	if !v.Valid() {
+		app.failedValidation(w, r, &v)
+		return
+	}
+
+	ready, waiting, err := app.todos.Next(app.authenticatedUserID(r), listID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	response := NextResponse{
+		Ready:   newTodoResponses(ready),
+		Waiting: newTodoResponses(waiting),
+	}
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
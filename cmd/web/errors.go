@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// Every error response is an RFC 7807 "problem details" document, so that
+// clients can handle all failures the same way.
+const problemContentType = "application/problem+json"
+
+// Problem types for failures that carry more meaning than their status code.
+// Any other failure uses "about:blank", whose title is the HTTP status text.
+const (
+	problemTypeBlank              = "about:blank"
+	problemTypeValidation         = "/problems/validation-error"
+	problemTypeInvalidCredentials = "/problems/invalid-credentials"
+	problemTypeDuplicateEmail     = "/problems/duplicate-email"
+	problemTypeDuplicateName      = "/problems/duplicate-name"
+	problemTypeCSRF               = "/problems/csrf-token"
+)
+
+// problem holds the members of a problem details document, plus the request ID
+// and an "errors" extension describing validation failures
+type problem struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	Status    int            `json:"status"`
+	Detail    string         `json:"detail,omitempty"`
+	Instance  string         `json:"instance,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+	Errors    *problemErrors `json:"errors,omitempty"`
+}
+
+// problemErrors mirrors validator.Validator
+type problemErrors struct {
+	Fields    map[string]string `json:"fields,omitempty"`
+	NonFields []string          `json:"non_fields,omitempty"`
+}
+
+// newProblem builds a problem of the given type for the current request
+func newProblem(r *http.Request, status int, problemType, detail string) *problem {
+	title := http.StatusText(status)
+	if problemType == "" {
+		problemType = problemTypeBlank
+	}
+
+	return &problem{
+		Type:      problemType,
+		Title:     title,
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: requestIDFromContext(r.Context()),
+	}
+}
+
+// withValidator attaches the field and non-field errors of v to the problem
+func (p *problem) withValidator(v *validator.Validator) *problem {
+	if v != nil && !v.Valid() {
+		p.Errors = &problemErrors{
+			Fields:    v.FieldErrors,
+			NonFields: v.NonFieldErrors,
+		}
+	}
+	return p
+}
+
+// writeProblem sends a problem as an application/problem+json response
+func writeProblem(w http.ResponseWriter, p *problem) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// The errorResponse helper sends a problem with the given status and detail,
+// and is the building block for the more specific helpers below.
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	writeProblem(w, newProblem(r, status, problemTypeBlank, detail))
+}
+
+// The serverError helper writes an error message and stack trace to the errorLog,
+// then sends a generic 500 Internal Server Error response to the user.
+// -- use the debug.Stack() function to get a stack trace for the current goroutine and append it to the
+// -- log message. Being able to see the execution path of the
+// -- application via the stack trace can be helpful when you’re trying to debug errors.
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	trace := fmt.Sprintf("[%s] %s\n%s", requestIDFromContext(r.Context()), err.Error(), debug.Stack())
+	// report the file name and line number one step back in the stack trace
+	// to have a clearer idea of where the error actually originated from
+	// set frame depth to 2
+	app.errorLog.Output(2, trace)
+
+	// the error itself is not exposed to the user, the request ID
+	// is enough to find it in the logs
+	app.errorResponse(w, r, http.StatusInternalServerError, "")
+}
+
+// The clientError helper sends a specific status code and corresponding description
+// to the user, like 400 "Bad Request" when there's a problem with the request that
+// the user sent.
+func (app *application) clientError(w http.ResponseWriter, r *http.Request, status int) {
+	app.errorResponse(w, r, status, "")
+}
+
+// The badRequest helper sends a 400 Bad Request response explaining what
+// was wrong with the request.
+func (app *application) badRequest(w http.ResponseWriter, r *http.Request, err error) {
+	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+}
+
+// For consistency, we'll also implement a notFound helper. This is simply a
+// convenience wrapper around clientError which sends a 404 Not Found
+// response to the user.
+func (app *application) notFound(w http.ResponseWriter, r *http.Request) {
+	app.clientError(w, r, http.StatusNotFound)
+}
+
+// The forbidden helper sends a 403 Forbidden response when the user can see
+// a record but is not allowed to change it.
+func (app *application) forbidden(w http.ResponseWriter, r *http.Request, detail string) {
+	app.errorResponse(w, r, http.StatusForbidden, detail)
+}
+
+// The blocked helper sends a 409 Conflict response when a todo cannot be
+// completed while the todos it waits for are open.
+func (app *application) blocked(w http.ResponseWriter, r *http.Request) {
+	app.errorResponse(w, r, http.StatusConflict, "The todo waits for open todos, complete them first or use ?force=true")
+}
+
+// The methodNotAllowed helper is used by the router when a route exists for the
+// requested path but not for the requested method.
+func (app *application) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, fmt.Sprintf("The %s method is not supported for this resource", r.Method))
+}
+
+// The failedValidation helper sends a 400 Bad Request response carrying the
+// field and non-field errors collected by a validator.
+func (app *application) failedValidation(w http.ResponseWriter, r *http.Request, v *validator.Validator) {
+	p := newProblem(r, http.StatusBadRequest, problemTypeValidation, "The submitted data is invalid")
+	writeProblem(w, p.withValidator(v))
+}
+
+// The duplicateName helper sends a 409 Conflict response when a name
+// must be unique among the records of a user.
+func (app *application) duplicateName(w http.ResponseWriter, r *http.Request, v *validator.Validator) {
+	v.AddFieldError("name", "This name is already in use")
+	p := newProblem(r, http.StatusConflict, problemTypeDuplicateName, "A record with this name already exists")
+	writeProblem(w, p.withValidator(v))
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// Response struct for comparing the estimates of a user in one unit with
+// the actual durations of the todos
+type EstimateAccuracyResponse struct {
+	UserID        string `json:"user_id"`
+	UserName      string `json:"user_name"`
+	Unit          string `json:"unit"`
+	Todos         int    `json:"todos"`
+	Estimated     int    `json:"estimated"`
+	ActualSeconds int64  `json:"actual_seconds"`
+	// actual over estimated duration, null for points
+	Ratio *float64 `json:"ratio"`
+	// average actual duration of a point, null for minutes
+	PointSeconds *int64                 `json:"point_seconds"`
+	Weeks        []EstimateWeekResponse `json:"weeks"`
+}
+
+// Response struct for comparing the estimates with the actual durations of
+// the todos completed in a week
+type EstimateWeekResponse struct {
+	// the Monday starting the week
+	Week          string  `json:"week"`
+	Todos         int     `json:"todos"`
+	Estimated     int     `json:"estimated"`
+	ActualSeconds int64   `json:"actual_seconds"`
+	Ratio         float64 `json:"ratio"`
+}
+
+// Response struct for returning the accuracy of the estimates over a range
+// of days
+type EstimateReportResponse struct {
+	From  string                     `json:"from"`
+	To    string                     `json:"to"`
+	Users []EstimateAccuracyResponse `json:"users"`
+}
+
+// compare the estimates of each user with the time the todos took from
+// their creation to their completion. from and to are dates in the time
+// zone of the user and default to the last 30 days, ?list= limits the
+// report to the todos of one list.
+func (app *application) todoEstimates(w http.ResponseWriter, r *http.Request) {
+	loc, err := app.userLocation(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	qs := r.URL.Query()
+	q := readStatsQuery(qs, loc)
+	listID := readListParam(qs, &q.Validator)
+	if !q.Valid() {
+		app.failedValidation(w, r, &q.Validator)
+		return
+	}
+
+	accuracies, err := app.todos.Estimates(app.authenticatedUserID(r), listID, q.From, q.To)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	response := EstimateReportResponse{
+		From:  q.From.Format(time.DateOnly),
+		To:    q.To.Format(time.DateOnly),
+		Users: make([]EstimateAccuracyResponse, 0, len(accuracies)),
+	}
+	for _, a := range accuracies {
+		user := EstimateAccuracyResponse{
+			UserID:        a.UserID,
+			UserName:      a.UserName,
+			Unit:          a.Unit,
+			Todos:         a.Todos,
+			Estimated:     a.Estimated,
+			ActualSeconds: int64(a.Actual.Seconds()),
+			Ratio:         a.Ratio,
+			Weeks:         make([]EstimateWeekResponse, 0, len(a.Weeks)),
+		}
+		if a.PointDuration != nil {
+			seconds := int64(a.PointDuration.Seconds())
+			user.PointSeconds = &seconds
+		}
+		for _, week := range a.Weeks {
+			user.Weeks = append(user.Weeks, EstimateWeekResponse{
+				Week:          week.Start.Format(time.DateOnly),
+				Todos:         week.Todos,
+				Estimated:     week.Estimated,
+				ActualSeconds: int64(week.Actual.Seconds()),
+				Ratio:         week.Ratio,
+			})
+		}
+		response.Users = append(response.Users, user)
+	}
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
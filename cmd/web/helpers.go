@@ -3,54 +3,179 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"runtime/debug"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/recurrence"
 	"todo-backend.kweeuhree/internal/validator"
 )
 
-// The serverError helper writes an error message and stack trace to the errorLog,
-// then sends a generic 500 Internal Server Error response to the user.
-// -- use the debug.Stack() function to get a stack trace for the current goroutine and append it to the
-// -- log message. Being able to see the execution path of the
-// -- application via the stack trace can be helpful when you’re trying to debug errors.
-func (app *application) serverError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	// report the file name and line number one step back in the stack trace
-	// to have a clearer idea of where the error actually originated from
-	// set frame depth to 2
-	app.errorLog.Output(2, trace)
+// Return the "id" named parameter of the current route if it is a valid UUID,
+// otherwise return an empty string
+func readIDParam(r *http.Request) string {
+	return readUUIDParam(r, "id")
+}
 
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+// Return a named UUID parameter of the current route, or an empty string if
+// it is missing or malformed
+func readUUIDParam(r *http.Request, name string) string {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := uuid.Parse(params.ByName(name))
+	if err != nil {
+		return ""
+	}
+	return id.String()
 }
 
-// The clientError helper sends a specific status code and corresponding description
-// to the user. We'll use this later in the book to send responses like 400
-// "Bad Request" when there's a problem with the request that the user sent.
-// -- use the http.StatusText() function to automatically generate a human-friendly text
-// representation of a given HTTP status code. For example,
-// http.StatusText(400) will return the string "Bad Request".
-func (app *application) clientError(w http.ResponseWriter, status int) {
-	http.Error(w, http.StatusText(status), status)
+// The decodeJSON helper decodes a JSON request body into dst. If the body cannot
+// be decoded it sends a 400 Bad Request problem describing why, and returns the
+// error so that the handler can stop processing the request.
+func (app *application) decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	// limit the size of the request body to 1MB
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	err := json.NewDecoder(r.Body).Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var typeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			err = fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			err = errors.New("body contains badly-formed JSON")
+		case errors.As(err, &typeError):
+			if typeError.Field != "" {
+				err = fmt.Errorf("body contains incorrect JSON type for field %q", typeError.Field)
+			} else {
+				err = fmt.Errorf("body contains incorrect JSON type (at character %d)", typeError.Offset)
+			}
+		case errors.Is(err, io.EOF):
+			err = errors.New("body must not be empty")
+		case errors.As(err, &maxBytesError):
+			err = fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		}
+
+		app.badRequest(w, r, err)
+		return err
+	}
+	return nil
 }
 
-// For consistency, we'll also implement a notFound helper. This is simply a
-// convenience wrapper around clientError which sends a 404 Not Found
-// response to the user.
-func (app *application) notFound(w http.ResponseWriter) {
-	app.clientError(w, http.StatusNotFound)
+// optional wraps a field of a partial update so that a missing field (leave the
+// value unchanged) can be told apart from an explicit null (clear the value)
+type optional[T any] struct {
+	Set   bool
+	Value *T
 }
 
-func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
-	err := json.NewDecoder(r.Body).Decode(dst)
+// UnmarshalJSON is only called when the field is present in the body
+func (o *optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Value = nil
+		return nil
+	}
+
+	var v T
+	err := json.Unmarshal(data, &v)
 	if err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
 		return err
 	}
+	o.Value = &v
 	return nil
 }
 
+// The readString helper returns a string value from the query string, or the
+// provided default value if no matching key could be found.
+func readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	return s
+}
+
+// The readCSV helper reads the values of a key from the query string, which may
+// be repeated and/or comma separated, e.g. ?tag=work,home&tag=urgent. Empty
+// values are skipped.
+func readCSV(qs url.Values, key string) []string {
+	values := []string{}
+	for _, s := range qs[key] {
+		for _, v := range strings.Split(s, ",") {
+			v = strings.TrimSpace(v)
+			if v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// The readInt helper reads a string value from the query string and converts it
+// to an integer before returning. If no matching key could be found it returns
+// the provided default value. If the value couldn't be converted to an integer,
+// then we record an error message in the provided Validator instance.
+func readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddFieldError(key, "This field must be an integer value")
+		return defaultValue
+	}
+	return i
+}
+
+// The readTime helper reads an RFC 3339 timestamp from the query string. It
+// returns the zero time if the key is missing, and records an error message in
+// the provided Validator instance if the value is malformed.
+func readTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		v.AddFieldError(key, "This field must be an RFC 3339 timestamp")
+		return time.Time{}
+	}
+	return t
+}
+
+// The readDate helper reads a date such as 2024-05-31 from the query string,
+// as the midnight starting that day in loc. It returns the zero time if the
+// key is missing, and records an error message in the provided Validator
+// instance if the value is malformed.
+func readDate(qs url.Values, key string, loc *time.Location, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return time.Time{}
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		v.AddFieldError(key, "This field must be a date such as 2024-05-31")
+		return time.Time{}
+	}
+	return t
+}
+
 func encodeJSON(w http.ResponseWriter, status int, data interface{}) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -70,16 +195,226 @@ func (app *application) getFlash(ctx context.Context) string {
 func (input *TodoInput) Validate() {
 	input.CheckField(validator.NotBlank(input.Body), "body", "This field cannot be blank")
 	input.CheckField(validator.MaxChars(input.Body, 200), "body", "This field cannot be more than 200 characters long")
+	input.CheckField(validator.MaxChars(input.Notes, maxNotesChars), "notes", "This field cannot be more than 10000 characters long")
+	validateSchedule(&input.Validator, input.StartAt, input.DueAt)
+	if input.Priority != "" {
+		input.CheckField(validator.PermittedString(input.Priority, models.PriorityNames...), "priority", "This field must be one of none, low, medium, high or urgent")
+	}
+	validateRRule(&input.Validator, input.RRule)
+	validateEstimate(&input.Validator, input.Estimate, input.estimateUnit)
+}
+
+// largest estimate in each unit: a week of work, or 100 story points
+var maxEstimate = map[string]int{
+	models.EstimateMinutes: 7 * 24 * 60,
+	models.EstimatePoints:  100,
+}
+
+// checks an optional estimate, given in unit
+func validateEstimate(v *validator.Validator, estimate *int, unit string) {
+	if estimate == nil {
+		return
+	}
+	max := maxEstimate[unit]
+	v.CheckField(validator.IntBetween(*estimate, 1, max), "estimate", fmt.Sprintf("This field must be between 1 and %d %s", max, unit))
+}
+
+// checks an optional recurrence rule, reporting what is wrong with it
+func validateRRule(v *validator.Validator, rrule string) {
+	if rrule == "" {
+		return
+	}
+	v.CheckField(validator.MaxChars(rrule, 255), "rrule", "This field cannot be more than 255 characters long")
+	if _, err := recurrence.Parse(rrule); err != nil {
+		reason := strings.TrimPrefix(err.Error(), recurrence.ErrInvalidRule.Error()+": ")
+		v.AddFieldError("rrule", "This field must be a valid recurrence rule: "+reason)
+	}
+}
+
+// todo dates must fit in a MySQL DATETIME column
+var (
+	minTodoTime = time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+	maxTodoTime = time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC)
+)
+
+// checks the optional start and due dates of a todo, a todo
+// cannot start after it is due
+func validateSchedule(v *validator.Validator, startAt, dueAt *time.Time) {
+	if startAt != nil {
+		v.CheckField(validator.TimeBetween(*startAt, minTodoTime, maxTodoTime), "start_at", "This field must be a date between 1970 and 9999")
+	}
+	if dueAt != nil {
+		v.CheckField(validator.TimeBetween(*dueAt, minTodoTime, maxTodoTime), "due_at", "This field must be a date between 1970 and 9999")
+	}
+	if startAt != nil && dueAt != nil {
+		v.CheckField(validator.NotAfter(*startAt, *dueAt), "start_at", "This field cannot be later than due_at")
+	}
+}
+
+// a partial update only validates the fields that were provided
+func (input *TodoPatchInput) Validate() {
+	if input.Body != nil {
+		input.CheckField(validator.NotBlank(*input.Body), "body", "This field cannot be blank")
+		input.CheckField(validator.MaxChars(*input.Body, 200), "body", "This field cannot be more than 200 characters long")
+	}
+	if input.Notes != nil {
+		input.CheckField(validator.MaxChars(*input.Notes, maxNotesChars), "notes", "This field cannot be more than 10000 characters long")
+	}
+	if input.Priority != nil {
+		input.CheckField(validator.PermittedString(*input.Priority, models.PriorityNames...), "priority", "This field must be one of none, low, medium, high or urgent")
+	}
+	if input.RRule != nil {
+		validateRRule(&input.Validator, *input.RRule)
+	}
+	validateEstimate(&input.Validator, input.Estimate.Value, input.estimateUnit)
+}
+
+// checks the paging and sorting parameters of the todo list,
+// the remaining filters are checked as they are read
+func (q *todoListQuery) Validate() {
+	q.CheckField(q.Filter.Limit >= 1 && q.Filter.Limit <= 100, "limit", "This field must be between 1 and 100")
+	q.CheckField(validator.PermittedString(q.Filter.Sort, models.TodoSortSafelist...), "sort", "This field must be one of created, -created, body or position")
+	if !q.Filter.CreatedAfter.IsZero() && !q.Filter.CreatedBefore.IsZero() {
+		q.CheckField(q.Filter.CreatedAfter.Before(q.Filter.CreatedBefore), "created_before", "This field must be later than created_after")
+	}
+	q.CheckField(validator.PermittedString(q.Due, "", "today", "overdue", "this_week"), "due", "This field must be one of today, overdue or this_week")
+	q.CheckField(len(q.Filter.Tags) <= 20, "tag", "This field cannot list more than 20 tags")
+	if q.Due == "overdue" && q.Filter.Status != nil {
+		q.CheckField(!*q.Filter.Status, "status", "Done todos cannot be overdue")
+	}
+}
+
+// checks the range of days of the statistics
+func (q *statsQuery) Validate() {
+	q.CheckField(!q.From.After(q.To), "to", "This field must not be earlier than from")
+	q.CheckField(q.To.Before(q.From.AddDate(0, 0, maxStatsDays)), "from", "The range cannot be longer than 366 days")
+}
+
+// attachments must be named, non-empty screenshots or PDFs
+func (input *attachmentUpload) Validate() {
+	input.CheckField(validator.NotBlank(input.Filename), "file", "The file must have a name")
+	input.CheckField(validator.MaxChars(input.Filename, 255), "file", "The file name cannot be more than 255 characters long")
+	input.CheckField(!strings.ContainsFunc(input.Filename, unicode.IsControl), "file", "The file name cannot contain control characters")
+	input.CheckField(!input.Empty, "file", "The file cannot be empty")
+	if !input.Empty {
+		input.CheckField(validator.PermittedString(input.ContentType, attachmentContentTypes...), "file", "The file must be a PNG, JPEG, GIF or WebP image, or a PDF document")
+	}
+}
+
+// the neighbours of a moved todo are optional
+func (input *TodoMoveInput) Validate() {
+	if input.After != nil {
+		_, err := uuid.Parse(*input.After)
+		input.CheckField(err == nil, "after", "This field must be null or the ID of one of your todos")
+	}
+	if input.Before != nil {
+		_, err := uuid.Parse(*input.Before)
+		input.CheckField(err == nil, "before", "This field must be null or the ID of one of your todos")
+	}
+}
+
+// comments are plain text
+func (input *CommentInput) Validate() {
+	input.CheckField(validator.NotBlank(input.Body), "body", "This field cannot be blank")
+	input.CheckField(validator.MaxChars(input.Body, maxCommentChars), "body", "This field cannot be more than 2000 characters long")
+}
+
+// time entries added by hand are in the past, and a running one has no
+// stop time
+func (input *TimeEntryInput) Validate() {
+	now := time.Now()
+	input.CheckField(input.StartedAt != nil, "started_at", "This field is required")
+	if input.StartedAt != nil {
+		input.CheckField(!input.StartedAt.After(now), "started_at", "This field cannot be in the future")
+	}
+	if input.StartedAt != nil && input.StoppedAt != nil {
+		input.CheckField(input.StoppedAt.After(*input.StartedAt), "stopped_at", "This field must be later than started_at")
+		input.CheckField(!input.StoppedAt.After(now), "stopped_at", "This field cannot be in the future")
+		input.CheckField(input.StoppedAt.Sub(*input.StartedAt) <= maxTimeEntry, "stopped_at", "An entry cannot be longer than 24 hours")
+	}
+}
+
+func (input *MemberInput) Validate() {
+	input.CheckField(validator.NotBlank(input.Email), "email", "This field cannot be blank")
+	input.CheckField(validator.Matches(input.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	input.CheckField(validator.PermittedString(input.Role, models.Roles...), "role", "This field must be viewer, editor or owner")
+}
+
+func (input *AssigneeInput) Validate() {
+	input.CheckField(validator.NotBlank(input.Email), "email", "This field cannot be blank")
+	input.CheckField(validator.Matches(input.Email, validator.EmailRX), "email", "This field must be a valid email address")
+}
+
+func (input *MemberRoleInput) Validate() {
+	input.CheckField(validator.PermittedString(input.Role, models.Roles...), "role", "This field must be viewer, editor or owner")
+}
+
+func (input *StateInput) Validate() {
+	input.CheckField(validator.NotBlank(input.Name), "name", "This field cannot be blank")
+	input.CheckField(validator.MaxChars(input.Name, 50), "name", "This field cannot be more than 50 characters long")
+	for _, id := range input.Next {
+		_, err := uuid.Parse(id)
+		input.CheckField(err == nil, "next", "This field must only hold IDs of states of your workflow")
+	}
+}
+
+func (input *TodoStateInput) Validate() {
+	_, err := uuid.Parse(input.StateID)
+	input.CheckField(err == nil, "state_id", "This field must be the ID of a state of the workflow of the list")
+}
+
+func (input *SnoozeInput) Validate() {
+	input.CheckField(validator.NotBlank(input.Until), "until", "This field cannot be blank")
+}
+
+func (input *TagInput) Validate() {
+	input.CheckField(validator.NotBlank(input.Name), "name", "This field cannot be blank")
+	input.CheckField(validator.MaxChars(input.Name, 50), "name", "This field cannot be more than 50 characters long")
+	input.CheckField(!strings.Contains(input.Name, ","), "name", "This field cannot contain commas")
+	input.CheckField(validator.Matches(input.Color, validator.ColorRX), "color", "This field must be a color such as #ff8800")
+}
+
+func (input *TemplateInput) Validate() {
+	input.CheckField(validator.NotBlank(input.Name), "name", "This field cannot be blank")
+	input.CheckField(validator.MaxChars(input.Name, 100), "name", "This field cannot be more than 100 characters long")
+	input.CheckField(len(input.TodoIDs) > 0, "todo_ids", "This field must hold at least one todo")
+	for _, id := range input.TodoIDs {
+		_, err := uuid.Parse(id)
+		input.CheckField(err == nil, "todo_ids", "This field must only hold IDs of todos you can see")
+	}
+}
+
+func (input *ListInput) Validate() {
+	input.CheckField(validator.NotBlank(input.Name), "name", "This field cannot be blank")
+	input.CheckField(validator.MaxChars(input.Name, 100), "name", "This field cannot be more than 100 characters long")
+	if input.Color != "" {
+		input.CheckField(validator.Matches(input.Color, validator.ColorRX), "color", "This field must be a color such as #ff8800")
+	}
+	input.CheckField(validator.MaxChars(input.Icon, 50), "icon", "This field cannot be more than 50 characters long")
 }
 
 func (form *userSignUpInput) Validate() {
 	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.ValidTimezone(form.Timezone), "timezone", "This field must be an IANA time zone such as Europe/Berlin")
 	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
 	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
 	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
 	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
 }
 
+// a profile update only validates the fields that were provided
+func (form *userUpdateInput) Validate() {
+	if form.Name != nil {
+		form.CheckField(validator.NotBlank(*form.Name), "name", "This field cannot be blank")
+	}
+	if form.Timezone != nil {
+		form.CheckField(validator.ValidTimezone(*form.Timezone), "timezone", "This field must be an IANA time zone such as Europe/Berlin")
+	}
+	if form.EstimateUnit != nil {
+		form.CheckField(validator.PermittedString(*form.EstimateUnit, models.EstimateUnits...), "estimate_unit", "This field must be minutes or points")
+	}
+}
+
 // checks that email and password are provided
 // and also check the format of the email address as
 // a UX-nicety (in case the user makes a typo).
@@ -99,3 +434,59 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// Return the role of the user in the list of the current request, as found
+// by requireRole, or an empty string for routes without a list
+func (app *application) listRole(r *http.Request) string {
+	role, _ := r.Context().Value(listRoleContextKey).(string)
+	return role
+}
+
+// Return the ID of the user making the current request, or an empty string
+// if the request is not authenticated
+func (app *application) authenticatedUserID(r *http.Request) string {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(string)
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+// Return the ID assigned to the current request by the requestID middleware
+func requestIDFromContext(ctx context.Context) string {
+	id, ok := ctx.Value(requestIDContextKey).(string)
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+// Return the time zone of the user making the current request, falling
+// back to UTC if the stored zone is unknown to this server
+func (app *application) userLocation(r *http.Request) (*time.Location, error) {
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		app.errorLog.Printf("Unknown time zone %q for user %s: %s", user.Timezone, user.Uuid, err)
+		return time.UTC, nil
+	}
+	return loc, nil
+}
+
+// estimateUnit returns the unit the authenticated user estimates todos in,
+// without reading it when estimate is nil
+func (app *application) estimateUnit(r *http.Request, estimate *int) (string, error) {
+	if estimate == nil {
+		return "", nil
+	}
+
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		return "", err
+	}
+	return user.EstimateUnit, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"todo-backend.kweeuhree/internal/models"
+)
+
+// Response struct for returning one field changed by a revision
+type ChangeResponse struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// Response struct for returning a revision of a todo
+type RevisionResponse struct {
+	ID        string           `json:"id"`
+	Action    string           `json:"action"`
+	ActorID   string           `json:"actor_id"`
+	ActorName string           `json:"actor_name"`
+	Created   time.Time        `json:"created"`
+	Changes   []ChangeResponse `json:"changes"`
+}
+
+// Response struct for returning the history of a todo, most recent first
+type HistoryResponse struct {
+	Revisions []RevisionResponse `json:"revisions"`
+}
+
+// newRevisionResponse converts a revision into its JSON representation,
+// priorities are given by name as in TodoResponse
+func newRevisionResponse(rev *models.Revision) RevisionResponse {
+	changes := []ChangeResponse{}
+	for _, c := range rev.Changes() {
+		change := ChangeResponse{Field: c.Field, Before: c.Before, After: c.After}
+		if c.Field == "priority" {
+			change.Before = models.PriorityNames[c.Before.(int)]
+			change.After = models.PriorityNames[c.After.(int)]
+		}
+		changes = append(changes, change)
+	}
+
+	return RevisionResponse{
+		ID:        rev.ID,
+		Action:    rev.Action,
+		ActorID:   rev.ActorID,
+		ActorName: rev.ActorName,
+		Created:   rev.Created,
+		Changes:   changes,
+	}
+}
+
+// history of the changes made to a todo
+func (app *application) todoHistory(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	revisions, err := app.todos.History(app.authenticatedUserID(r), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	response := HistoryResponse{Revisions: make([]RevisionResponse, 0, len(revisions))}
+	for _, rev := range revisions {
+		response.Revisions = append(response.Revisions, newRevisionResponse(rev))
+	}
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// bring a todo back to its state as of one of its revisions. Completing a
+// todo waiting for open todos that way requires ?force=true.
+func (app *application) todoRevert(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	revisionID := readUUIDParam(r, "revision")
+	if id == "" || revisionID == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	err := app.todos.Revert(userID, id, revisionID, readForce(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w, r)
+		case errors.Is(err, models.ErrBlocked):
+			app.blocked(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.setFlash(r.Context(), "Todo has been reverted.")
+	app.writeTodo(w, r, userID, id)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"todo-backend.kweeuhree/internal/models"
+)
+
+// runJob runs a background job, logging instead of crashing the
+// server if it panics
+func (app *application) runJob(name string, job func() error) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.errorLog.Output(2, fmt.Sprintf("%s: panic: %v", name, err))
+		}
+	}()
+
+	err := job()
+	if err != nil {
+		app.errorLog.Printf("%s: %s", name, err)
+	}
+}
+
+// interval between two purges of the trash
+const purgeInterval = time.Hour
+
+// purgeTrash permanently deletes the todos which have been in the trash for
+// more than days days, with their attachments, every purgeInterval
+func (app *application) purgeTrash(days int) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		app.runJob("purge trash", func() error {
+			before := time.Now().AddDate(0, 0, -days)
+			checksums, err := app.attachments.ChecksumsDeletedBefore(before)
+			if err != nil {
+				return err
+			}
+
+			n, err := app.todos.PurgeDeletedBefore(before)
+			if n > 0 {
+				app.infoLog.Printf("Purged %d todos from the trash", n)
+			}
+			if err != nil {
+				return err
+			}
+
+			app.removeContents(checksums...)
+			return nil
+		})
+	}
+}
+
+// rebalancePositions rewrites the positions of the todos of lists whose
+// ranks have grown long, every interval
+func (app *application) rebalancePositions(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		app.runJob("rebalance positions", func() error {
+			n, err := app.todos.RebalancePositions(models.RankRebalanceLength)
+			if n > 0 {
+				app.infoLog.Printf("Rebalanced the todo positions of %d lists", n)
+			}
+			return err
+		})
+	}
+}
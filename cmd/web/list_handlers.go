@@ -0,0 +1,317 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// Input struct for creating and updating lists
+type ListInput struct {
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	Icon     string `json:"icon"`
+	Archived bool   `json:"archived"`
+	validator.Validator
+}
+
+// Input struct for moving a todo to another list
+type TodoMoveListInput struct {
+	ListID string `json:"list_id"`
+	validator.Validator
+}
+
+// Response struct for returning list data
+type ListResponse struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Color    string    `json:"color"`
+	Icon     string    `json:"icon"`
+	Archived bool      `json:"archived"`
+	Inbox    bool      `json:"inbox"`
+	Role     string    `json:"role"`
+	Created  time.Time `json:"created"`
+}
+
+// Response struct for returning a list of lists
+type ListListResponse struct {
+	Lists []ListResponse `json:"lists"`
+}
+
+// newListResponse converts a list model into its JSON representation
+func newListResponse(l *models.List) ListResponse {
+	return ListResponse{
+		ID:       l.ID,
+		Name:     l.Name,
+		Color:    l.Color,
+		Icon:     l.Icon,
+		Archived: l.Archived,
+		Inbox:    l.Inbox,
+		Role:     l.Role,
+		Created:  l.Created,
+	}
+}
+
+// list, archived lists are included with ?archived=true
+func (app *application) listList(w http.ResponseWriter, r *http.Request) {
+	includeArchived := readString(r.URL.Query(), "archived", "false") == "true"
+
+	userID := app.authenticatedUserID(r)
+
+	// make sure users created before lists existed have an inbox
+	_, err := app.lists.Inbox(userID, uuid.New().String())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	lists, err := app.lists.All(userID, includeArchived)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	response := ListListResponse{Lists: make([]ListResponse, 0, len(lists))}
+	for _, l := range lists {
+		response.Lists = append(response.Lists, newListResponse(l))
+	}
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// read
+func (app *application) listView(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	list, err := app.lists.Get(app.authenticatedUserID(r), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, newListResponse(list))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// create
+func (app *application) listCreate(w http.ResponseWriter, r *http.Request) {
+	var input ListInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	list := &models.List{
+		ID:       uuid.New().String(),
+		UserID:   userID,
+		Name:     input.Name,
+		Color:    input.Color,
+		Icon:     input.Icon,
+		Archived: input.Archived,
+	}
+
+	_, err = app.lists.Insert(list)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateName) {
+			app.duplicateName(w, r, &input.Validator)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	list, err = app.lists.Get(userID, list.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, http.StatusCreated, newListResponse(list))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// update
+func (app *application) listUpdate(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	var input ListInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	err = app.lists.Update(userID, &models.List{
+		ID:       id,
+		Name:     input.Name,
+		Color:    input.Color,
+		Icon:     input.Icon,
+		Archived: input.Archived,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w, r)
+		case errors.Is(err, models.ErrForbidden):
+			app.forbidden(w, r, "Only the owners of a list can change it")
+		case errors.Is(err, models.ErrDuplicateName):
+			app.duplicateName(w, r, &input.Validator)
+		case errors.Is(err, models.ErrInbox):
+			app.errorResponse(w, r, http.StatusConflict, "The inbox cannot be archived")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	list, err := app.lists.Get(userID, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, newListResponse(list))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// delete, the todos of the list are moved to the inbox
+func (app *application) listDelete(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	list, err := app.lists.Get(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.lists.Delete(userID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrForbidden):
+			app.forbidden(w, r, "Only the owners of a list can delete it")
+		case errors.Is(err, models.ErrInbox):
+			app.errorResponse(w, r, http.StatusConflict, "The inbox cannot be deleted")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, newListResponse(list))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// move a todo to another list
+func (app *application) todoMoveList(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	var input TodoMoveListInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	err = app.checkListID(&input.Validator, userID, input.ListID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	err = app.todos.SetList(userID, id, input.ListID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.setFlash(r.Context(), "Todo has been moved.")
+	app.writeTodo(w, r, userID, id)
+}
+
+// checkListID records a field error unless listID is the ID of a list
+// userID can add todos to, other failures are returned
+func (app *application) checkListID(v *validator.Validator, userID, listID string) error {
+	if _, err := uuid.Parse(listID); err != nil {
+		v.AddFieldError("list_id", "This field must be the ID of a list you can edit")
+		return nil
+	}
+
+	l, err := app.lists.Get(userID, listID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			v.AddFieldError("list_id", "This field must be the ID of a list you can edit")
+			return nil
+		}
+		return err
+	}
+	if !models.RoleAtLeast(l.Role, models.RoleEditor) {
+		v.AddFieldError("list_id", "This field must be the ID of a list you can edit")
+	}
+	return nil
+}
@@ -10,8 +10,13 @@ import (
 	"os"
 	"time"
 
+	// embed the time zone database, so that user time zones can be
+	// resolved on hosts without one
+	_ "time/tzdata"
+
 	// models
 	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/storage"
 
 	// environment variables
 	"github.com/joho/godotenv"
@@ -28,11 +33,20 @@ import (
 // Define an application struct to hold the application-wide dependencies for
 // the web application.
 type application struct {
-	errorLog       *log.Logger
-	infoLog        *log.Logger
-	users          *models.UserModel
-	todos          *models.TodoModel
-	sessionManager *scs.SessionManager
+	errorLog          *log.Logger
+	infoLog           *log.Logger
+	users             *models.UserModel
+	todos             *models.TodoModel
+	tags              *models.TagModel
+	templates         *models.TemplateModel
+	lists             *models.ListModel
+	attachments       *models.AttachmentModel
+	comments          *models.CommentModel
+	states            *models.StateModel
+	timeEntries       *models.TimeEntryModel
+	storage           storage.Store
+	maxAttachmentSize int64
+	sessionManager    *scs.SessionManager
 }
 
 func main() {
@@ -47,11 +61,17 @@ func main() {
 	dbName := os.Getenv("DB_NAME")
 
 	// DSN string with loaded env variables
-	DSNstring := fmt.Sprintf("%s:%s@/%s?parseTime=true", dbUser, dbPassword, dbName)
+	// -- clientFoundRows makes UPDATE report matched rather than changed rows,
+	// -- so that the models can tell a missing record from an unchanged one
+	DSNstring := fmt.Sprintf("%s:%s@/%s?parseTime=true&clientFoundRows=true", dbUser, dbPassword, dbName)
 
 	// define  new command-line flag for the mysql dsn string
 	dsn := flag.String("dsn", DSNstring, "MySQL data source name")
 	addr := flag.String("addr", ":4000", "HTTP network address")
+	trashDays := flag.Int("trash-days", 30, "Number of days deleted todos are kept in the trash, 0 to keep them forever")
+	rebalanceInterval := flag.Duration("rebalance-interval", time.Hour, "Interval between rebalancings of the todo positions, 0 to disable")
+	storageDir := flag.String("storage-dir", "./uploads", "Directory where the content of attachments is stored")
+	maxAttachmentSize := flag.Int64("max-attachment-size", 10<<20, "Maximum size of an attachment in bytes")
 
 	// parse flags
 	flag.Parse()
@@ -70,6 +90,12 @@ func main() {
 	// the main() function exits
 	defer db.Close()
 
+	// content-addressed store for the attachments
+	attachmentStore, err := storage.NewLocalStore(*storageDir)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	// Create a new MySQL session store using the connection pool.
 	store := mysqlstore.New(db)
 	// Initialize a new session manager.
@@ -79,11 +105,20 @@ func main() {
 	sessionManager.Lifetime = 12 * time.Hour
 
 	app := &application{
-		errorLog:       errorLog,
-		infoLog:        infoLog,
-		users:          &models.UserModel{DB: db},
-		todos:          &models.TodoModel{DB: db},
-		sessionManager: sessionManager,
+		errorLog:          errorLog,
+		infoLog:           infoLog,
+		users:             &models.UserModel{DB: db},
+		todos:             &models.TodoModel{DB: db},
+		tags:              &models.TagModel{DB: db},
+		templates:         &models.TemplateModel{DB: db},
+		lists:             &models.ListModel{DB: db},
+		attachments:       &models.AttachmentModel{DB: db},
+		comments:          &models.CommentModel{DB: db},
+		states:            &models.StateModel{DB: db},
+		timeEntries:       &models.TimeEntryModel{DB: db},
+		storage:           attachmentStore,
+		maxAttachmentSize: *maxAttachmentSize,
+		sessionManager:    sessionManager,
 	}
 
 	// Initialize a tls.Config struct to hold the non-default TLS settings we
@@ -113,6 +148,15 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	// keep the ranks ordering the todos short
+	if *rebalanceInterval > 0 {
+		go app.rebalancePositions(*rebalanceInterval)
+	}
+	// empty the trash of old todos
+	if *trashDays > 0 {
+		go app.purgeTrash(*trashDays)
+	}
+
 	infoLog.Printf("Starting server on %s", *addr)
 
 	// ListenAndServeTLS() starts HTTPS server
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+)
+
+// notes must fit in a MySQL TEXT column, whatever their encoding
+const maxNotesChars = 10000
+
+// notes are rendered as CommonMark. Raw HTML is left out by goldmark, and
+// the output is sanitized against the user generated content allowlist, so
+// that it is safe to inject as is: it never contains scripts, event handlers
+// or inline styles, which the Content-Security-Policy would block anyway.
+// Both are safe for concurrent use.
+var (
+	markdown    = goldmark.New()
+	notesPolicy = bluemonday.UGCPolicy().RequireNoReferrerOnLinks(true).AddTargetBlankToFullyQualifiedLinks(true)
+)
+
+// renderNotes converts notes to sanitized HTML
+func renderNotes(notes string) (string, error) {
+	var buf bytes.Buffer
+	err := markdown.Convert([]byte(notes), &buf)
+	if err != nil {
+		return "", err
+	}
+	return notesPolicy.Sanitize(buf.String()), nil
+}
+
+// readRender reports whether the notes should be rendered (?render=html)
+func readRender(r *http.Request) bool {
+	return readString(r.URL.Query(), "render", "") == "html"
+}
+
+// renderTodoNotes fills in the rendered notes of todo responses
+// when they were requested with ?render=html
+func renderTodoNotes(r *http.Request, responses ...*TodoResponse) error {
+	if !readRender(r) {
+		return nil
+	}
+	for _, response := range responses {
+		html, err := renderNotes(response.Notes)
+		if err != nil {
+			return err
+		}
+		response.NotesHTML = html
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// Input struct for sharing a list with a registered user
+type MemberInput struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	validator.Validator
+}
+
+// Input struct for changing the role of a member
+type MemberRoleInput struct {
+	Role string `json:"role"`
+	validator.Validator
+}
+
+// Response struct for returning a member of a list
+type MemberResponse struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+	// true for the creator of the list
+	Creator bool      `json:"creator"`
+	Created time.Time `json:"created"`
+}
+
+// Response struct for returning the members of a list, owners first
+type MemberListResponse struct {
+	Members []MemberResponse `json:"members"`
+}
+
+// newMemberResponse converts a member model into its JSON representation
+func newMemberResponse(m *models.Member) MemberResponse {
+	return MemberResponse{
+		UserID:  m.UserID,
+		Name:    m.Name,
+		Email:   m.Email,
+		Role:    m.Role,
+		Creator: m.Creator,
+		Created: m.Created,
+	}
+}
+
+// list the members of a list
+func (app *application) memberList(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	app.writeMembers(w, r, http.StatusOK, app.authenticatedUserID(r), id)
+}
+
+// share a list with a registered user, found by their email
+func (app *application) memberAdd(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	var input MemberInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	member, err := app.users.GetByEmail(input.Email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			input.AddFieldError("email", "No user is registered with this email")
+			app.failedValidation(w, r, &input.Validator)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	err = app.lists.AddMember(userID, id, member.Uuid, input.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w, r)
+		case errors.Is(err, models.ErrForbidden):
+			app.forbidden(w, r, "Only the owners of a list can share it")
+		case errors.Is(err, models.ErrDuplicateMember):
+			app.errorResponse(w, r, http.StatusConflict, "This user is already a member of the list")
+		case errors.Is(err, models.ErrInbox):
+			app.errorResponse(w, r, http.StatusConflict, "The inbox cannot be shared")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.writeMembers(w, r, http.StatusCreated, userID, id)
+}
+
+// change the role of a member
+func (app *application) memberUpdate(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	memberID := readUUIDParam(r, "userId")
+	if id == "" || memberID == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	var input MemberRoleInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	err = app.lists.SetMemberRole(userID, id, memberID, input.Role)
+	if err != nil {
+		app.memberChangeError(w, r, err)
+		return
+	}
+
+	app.writeMembers(w, r, http.StatusOK, userID, id)
+}
+
+// remove a member from a list, or leave it when the member is the user
+func (app *application) memberRemove(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	memberID := readUUIDParam(r, "userId")
+	if id == "" || memberID == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	members, err := app.lists.Members(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	var member *models.Member
+	for _, m := range members {
+		if m.UserID == memberID {
+			member = m
+		}
+	}
+	if member == nil {
+		app.notFound(w, r)
+		return
+	}
+
+	err = app.lists.RemoveMember(userID, id, memberID)
+	if err != nil {
+		app.memberChangeError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, newMemberResponse(member))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// memberChangeError sends the response for a failed change of membership
+func (app *application) memberChangeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.notFound(w, r)
+	case errors.Is(err, models.ErrForbidden):
+		app.forbidden(w, r, "Only the owners of a list can manage its members")
+	case errors.Is(err, models.ErrListOwner):
+		app.errorResponse(w, r, http.StatusConflict, "The creator of a list stays one of its owners")
+	default:
+		app.serverError(w, r, err)
+	}
+}
+
+// writeMembers sends the members of a list as read back from the database
+func (app *application) writeMembers(w http.ResponseWriter, r *http.Request, status int, userID, listID string) {
+	members, err := app.lists.Members(userID, listID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	response := MemberListResponse{Members: make([]MemberResponse, 0, len(members))}
+	for _, m := range members {
+		response.Members = append(response.Members, newMemberResponse(m))
+	}
+
+	err = encodeJSON(w, status, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
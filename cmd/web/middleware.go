@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"regexp"
+
+	"github.com/google/uuid"
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/validator"
 
 	// environment variables
 	"github.com/joho/godotenv"
@@ -13,6 +19,10 @@ import (
 	"github.com/justinas/nosurf"
 )
 
+// request IDs supplied by clients are only trusted if they are short and
+// made of safe characters, since they end up in logs and responses
+var requestIDRX = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
 func secureHeaders(next http.Handler) http.Handler {
 
 	// Load environment variables from the .env file
@@ -28,10 +38,11 @@ func secureHeaders(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Origin", reactAddress)
 
 		// Allow specific HTTP methods
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 
 		// Allow specific headers
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "Deprecation, Link, Location, X-Request-ID")
 		w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
 		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
 		w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -42,6 +53,16 @@ func secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
+// deprecated marks the legacy, verb-shaped routes as superseded by /api/v1.
+// The handlers behave exactly as before, but clients are told to migrate.
+func deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", `</api/v1>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Println("requireAuthentication middleware triggered for", r.URL.Path)
@@ -50,11 +71,7 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 		// the chain are executed.
 		if !app.isAuthenticated(r) {
 			log.Println("Authenticated request blocked.")
-			response := map[string]string{
-				"status":  "401 Unauthorized",
-				"message": "You must be logged in to access this resource",
-			}
-			encodeJSON(w, http.StatusUnauthorized, response)
+			app.errorResponse(w, r, http.StatusUnauthorized, "You must be logged in to access this resource")
 			return
 		}
 		// Otherwise set the "Cache-Control: no-store" header so that pages
@@ -67,6 +84,56 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// requireTodoRole lets a request through if the user has at least role in
+// the list of the todo of the route, trashed or not
+func (app *application) requireTodoRole(role string) func(http.Handler) http.Handler {
+	return app.requireRole(role, app.todos.Role)
+}
+
+// requireListRole lets a request through if the user has at least role in
+// the list of the route
+func (app *application) requireListRole(role string) func(http.Handler) http.Handler {
+	return app.requireRole(role, app.lists.Role)
+}
+
+// requireRole is the authorization layer of the shared lists. It runs after
+// requireAuthentication, looks up the role of the user in the list holding
+// the record of the "id" route parameter, and only calls the next handler
+// if that role is at least role. Users who are not members of the list get
+// a 404 Not Found, as if the record did not exist, members with a lesser
+// role a 403 Forbidden.
+func (app *application) requireRole(role string, lookup func(userID, id string) (string, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := readIDParam(r)
+			if id == "" {
+				app.notFound(w, r)
+				return
+			}
+
+			current, err := lookup(app.authenticatedUserID(r), id)
+			if err != nil {
+				if errors.Is(err, models.ErrNoRecord) {
+					app.notFound(w, r)
+				} else {
+					app.serverError(w, r, err)
+				}
+				return
+			}
+
+			if !models.RoleAtLeast(current, role) {
+				app.forbidden(w, r, fmt.Sprintf("This action requires the %s role, you are a %s of this list", role, current))
+				return
+			}
+
+			// handlers which let some users do more than their role allows
+			// read the role back with listRole
+			ctx := context.WithValue(r.Context(), listRoleContextKey, current)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Println("authenticate middleware triggered for", r.URL.Path)
@@ -83,7 +150,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		// database.
 		exists, err := app.users.Exists(id)
 		if err != nil {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 			return
 		}
 		// If a matching user is found, we know that the request is
@@ -91,8 +158,11 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		// create a new copy of the request (with an isAuthenticatedContextKey
 
 		// value of true in the request context) and assign it to r.
+		// The user ID is stored alongside it so that handlers can scope
+		// their queries to the records owned by that user.
 		if exists {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
 			r = r.WithContext(ctx)
 		}
 		fmt.Println("User ID from session:", id)
@@ -114,6 +184,14 @@ func noSurf(next http.Handler) http.Handler {
 		Path:     "/",
 		Secure:   true,
 	})
+	// report a rejected token as a problem, like every other error
+	csrfHandler.SetFailureHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		detail := "CSRF token is missing or invalid"
+		if reason := nosurf.Reason(r); reason != nil {
+			detail = fmt.Sprintf("%s: %s", detail, reason)
+		}
+		writeProblem(w, newProblem(r, http.StatusBadRequest, problemTypeCSRF, detail))
+	}))
 	return csrfHandler
 }
 
@@ -128,13 +206,29 @@ func (app *application) CSRFToken(w http.ResponseWriter, r *http.Request) {
 
 	err := encodeJSON(w, http.StatusOK, map[string]string{"csrf_token": token})
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 	}
 }
 
+// requestID tags every request with an ID, reusing a well-formed X-Request-ID
+// sent by the client or a proxy. The ID is echoed in the response headers and
+// included in error responses and logs so that failures can be traced.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validator.Matches(id, requestIDRX) {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method,
+		app.infoLog.Printf("[%s] %s - %s %s %s", requestIDFromContext(r.Context()), r.RemoteAddr, r.Proto, r.Method,
 			r.URL.RequestURI())
 		next.ServeHTTP(w, r)
 	})
@@ -169,7 +263,7 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 				w.Header().Set("Connection", "close")
 				// Call the app.serverError helper method to return a 500
 				// Internal Server response.
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 		next.ServeHTTP(w, r)
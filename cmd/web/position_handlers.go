@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// Input struct for moving a todo in the manual order: After is the todo
+// which will come just before it, Before the one which will come just after
+// it. Either may be null, both being null moves the todo to the top.
+type TodoMoveInput struct {
+	After  *string `json:"after"`
+	Before *string `json:"before"`
+	validator.Validator
+}
+
+// neighbourID returns the neighbour ID, empty if it is null
+func neighbourID(id *string) string {
+	if id == nil {
+		return ""
+	}
+	return *id
+}
+
+// move a todo between two others in the manual order (sort=position)
+func (app *application) todoMove(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	var input TodoMoveInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	err = app.todos.Move(userID, id, neighbourID(input.After), neighbourID(input.Before))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w, r)
+		case errors.Is(err, models.ErrInvalidPosition):
+			input.AddNonFieldError("after and before must be other todos of the same list, with after placed above before")
+			app.failedValidation(w, r, &input.Validator)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.setFlash(r.Context(), "Todo has been moved.")
+	app.writeTodo(w, r, userID, id)
+}
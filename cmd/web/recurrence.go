@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/recurrence"
+)
+
+// canonicalRRule returns a validated recurrence rule in its canonical form,
+// so that equivalent rules are stored the same way
+func canonicalRRule(rrule string) string {
+	rule, err := recurrence.Parse(rrule)
+	if err != nil {
+		return ""
+	}
+	return rule.String()
+}
+
+// completeRecurring marks a recurring todo as done and creates its next
+// occurrence, whose location is sent in the Location header
+func (app *application) completeRecurring(w http.ResponseWriter, r *http.Request, t *models.Todo, cascade, force bool) error {
+	next, err := app.nextOccurrence(r, t)
+	if err != nil {
+		return err
+	}
+
+	err = app.todos.CompleteRecurring(app.authenticatedUserID(r), t.ID, next, cascade, force)
+	if err != nil {
+		return err
+	}
+
+	if next != nil {
+		w.Header().Set("Location", "/api/v1/todos/"+next.ID)
+	}
+	return nil
+}
+
+// nextOccurrence builds the occurrence following a recurring todo, or returns
+// nil once its series is over. The occurrence is due on the first date of the
+// rule after the due date of t (its start date if it has no due date, or now
+// if it is not scheduled), in the time zone of the user. A start date keeps
+// its distance to the due date. The occurrence is created by the user
+// completing t, see CompleteRecurring for the members who can take it over.
+func (app *application) nextOccurrence(r *http.Request, t *models.Todo) (*models.Todo, error) {
+	rule, err := recurrence.Parse(t.RRule)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := app.userLocation(r)
+	if err != nil {
+		return nil, err
+	}
+
+	current := time.Now()
+	switch {
+	case t.DueAt != nil:
+		current = *t.DueAt
+	case t.StartAt != nil:
+		current = *t.StartAt
+	}
+
+	nextAt, ok := rule.Next(current.In(loc))
+	if !ok || nextAt.After(maxTodoTime) {
+		return nil, nil
+	}
+	shift := nextAt.Sub(current)
+	nextAt = nextAt.UTC()
+
+	next := &models.Todo{
+		ID:       uuid.New().String(),
+		UserID:   app.authenticatedUserID(r),
+		ListID:   t.ListID,
+		ParentID: t.ParentID,
+		// the series stays with its assignee
+		AssigneeID: t.AssigneeID,
+		Body:       t.Body,
+		Notes:      t.Notes,
+		Priority:   t.Priority,
+		Important:  t.Important,
+		RRule:      rule.Rest().String(),
+		// and with its estimate
+		Estimate:     t.Estimate,
+		EstimateUnit: t.EstimateUnit,
+		EstimatedBy:  t.EstimatedBy,
+	}
+	if next.Estimate != nil && next.EstimatedBy == "" {
+		next.EstimatedBy = next.UserID
+	}
+
+	switch {
+	case t.DueAt != nil:
+		next.DueAt = &nextAt
+		if t.StartAt != nil {
+			startAt := t.StartAt.Add(shift).UTC()
+			next.StartAt = &startAt
+		}
+	case t.StartAt != nil:
+		next.StartAt = &nextAt
+	default:
+		next.DueAt = &nextAt
+	}
+
+	return next, nil
+}
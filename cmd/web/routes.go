@@ -6,6 +6,7 @@ import (
 
 	"github.com/julienschmidt/httprouter" // router
 	"github.com/justinas/alice"           // middleware
+	"todo-backend.kweeuhree/internal/models"
 )
 
 func (app *application) routes() http.Handler {
@@ -15,8 +16,10 @@ func (app *application) routes() http.Handler {
 	// Create a handler function which wraps our notFound() helper
 	// Assign it as the custom handler for 404 Not Found responses
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.notFound(w)
+		app.notFound(w, r)
 	})
+	// Do the same for 405 Method Not Allowed responses
+	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowed)
 
 	fileServer := http.FileServer(http.Dir("./ui/static/"))
 	router.Handler(http.MethodGet, "/static/*filepath", http.StripPrefix("/static", fileServer))
@@ -24,9 +27,6 @@ func (app *application) routes() http.Handler {
 	// uprotected application routes using the "dynamic" middleware chain, use nosurf middleware
 	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
 
-	// todo routes
-	router.Handler(http.MethodGet, "/api", dynamic.ThenFunc(app.home))
-	router.Handler(http.MethodGet, "/api/todo/view", dynamic.ThenFunc(app.todoView))
 	// csrf token route
 	router.Handler(http.MethodGet, "/api/csrf-token", dynamic.ThenFunc(app.CSRFToken))
 	// test
@@ -41,15 +41,115 @@ func (app *application) routes() http.Handler {
 	// protected application routes, which uses requireAuthentication middleware
 	protected := dynamic.Append(app.requireAuthentication)
 	log.Println("Setting up protected routes...")
-	router.Handler(http.MethodPost, "/api/todo/create", protected.ThenFunc(app.todoCreate)) // fixed path
-	router.Handler(http.MethodPut, "/api/todo/update/:id", protected.ThenFunc(app.todoUpdate))
-	router.Handler(http.MethodPut, "/api/todo/toggle-status/:id", protected.ThenFunc(app.todoToggleStatus))
-	router.Handler(http.MethodDelete, "/api/todo/delete/:id", protected.ThenFunc(app.todoDelete))
+
+	// authorization by the role of the user in the list holding the todo
+	// or list of the route, see requireRole
+	todoViewer := protected.Append(app.requireTodoRole(models.RoleViewer))
+	todoEditor := protected.Append(app.requireTodoRole(models.RoleEditor))
+	listViewer := protected.Append(app.requireListRole(models.RoleViewer))
+	listOwner := protected.Append(app.requireListRole(models.RoleOwner))
+
+	// versioned, resource-oriented api
+	// -- todos
+	router.Handler(http.MethodGet, "/api/v1/todos", protected.ThenFunc(app.todoList))
+	router.Handler(http.MethodPost, "/api/v1/todos", protected.ThenFunc(app.todoCreate))
+	router.Handler(http.MethodGet, "/api/v1/todos/:id", todoViewer.ThenFunc(app.todoView))
+	router.Handler(http.MethodPut, "/api/v1/todos/:id", todoEditor.ThenFunc(app.todoUpdate))
+	// -- assignees can change the status of a todo they cannot edit, see todoPatch
+	router.Handler(http.MethodPatch, "/api/v1/todos/:id", todoViewer.ThenFunc(app.todoPatch))
+	router.Handler(http.MethodDelete, "/api/v1/todos/:id", todoEditor.ThenFunc(app.todoDelete))
+	router.Handler(http.MethodPut, "/api/v1/todos/:id/tags/:tagId", todoEditor.ThenFunc(app.todoTagAttach))
+	router.Handler(http.MethodDelete, "/api/v1/todos/:id/tags/:tagId", todoEditor.ThenFunc(app.todoTagDetach))
+	router.Handler(http.MethodPut, "/api/v1/todos/:id/list", todoEditor.ThenFunc(app.todoMoveList))
+	router.Handler(http.MethodPut, "/api/v1/todos/:id/state", todoViewer.ThenFunc(app.todoSetState))
+	// -- assignees can snooze a todo they cannot edit, see snooze
+	router.Handler(http.MethodPost, "/api/v1/todos/:id/snooze", todoViewer.ThenFunc(app.todoSnooze))
+	router.Handler(http.MethodDelete, "/api/v1/todos/:id/snooze", todoViewer.ThenFunc(app.todoWake))
+	router.Handler(http.MethodPut, "/api/v1/todos/:id/assignee", todoEditor.ThenFunc(app.todoAssign))
+	router.Handler(http.MethodDelete, "/api/v1/todos/:id/assignee", todoEditor.ThenFunc(app.todoUnassign))
+	router.Handler(http.MethodPost, "/api/v1/todos/:id/subtasks", todoEditor.ThenFunc(app.subtaskCreate))
+	router.Handler(http.MethodPut, "/api/v1/todos/:id/parent", todoEditor.ThenFunc(app.todoReparent))
+	router.Handler(http.MethodGet, "/api/v1/todos/:id/tree", todoViewer.ThenFunc(app.todoTree))
+	router.Handler(http.MethodPost, "/api/v1/todos/:id/move", todoEditor.ThenFunc(app.todoMove))
+	router.Handler(http.MethodGet, "/api/v1/todos/:id/history", todoViewer.ThenFunc(app.todoHistory))
+	router.Handler(http.MethodPost, "/api/v1/todos/:id/revert/:revision", todoEditor.ThenFunc(app.todoRevert))
+	router.Handler(http.MethodGet, "/api/v1/todos/:id/attachments", todoViewer.ThenFunc(app.attachmentList))
+	router.Handler(http.MethodPost, "/api/v1/todos/:id/attachments", todoEditor.ThenFunc(app.attachmentCreate))
+	router.Handler(http.MethodGet, "/api/v1/todos/:id/attachments/:attachmentId", todoViewer.ThenFunc(app.attachmentDownload))
+	router.Handler(http.MethodDelete, "/api/v1/todos/:id/attachments/:attachmentId", todoEditor.ThenFunc(app.attachmentDelete))
+	router.Handler(http.MethodGet, "/api/v1/todos/:id/comments", todoViewer.ThenFunc(app.commentList))
+	router.Handler(http.MethodPost, "/api/v1/todos/:id/comments", todoEditor.ThenFunc(app.commentCreate))
+	router.Handler(http.MethodPut, "/api/v1/todos/:id/comments/:commentId", todoEditor.ThenFunc(app.commentUpdate))
+	router.Handler(http.MethodDelete, "/api/v1/todos/:id/comments/:commentId", todoEditor.ThenFunc(app.commentDelete))
+	router.Handler(http.MethodGet, "/api/v1/todos/:id/blockers", todoViewer.ThenFunc(app.dependencyList))
+	router.Handler(http.MethodPut, "/api/v1/todos/:id/blockers/:blockerId", todoEditor.ThenFunc(app.dependencyAdd))
+	router.Handler(http.MethodDelete, "/api/v1/todos/:id/blockers/:blockerId", todoEditor.ThenFunc(app.dependencyRemove))
+	// -- assignees can track time on a todo they cannot edit, and users can
+	// stop their timers on todos they cannot see anymore
+	router.Handler(http.MethodPost, "/api/v1/todos/:id/timer/start", todoViewer.ThenFunc(app.timerStart))
+	router.Handler(http.MethodPost, "/api/v1/todos/:id/timer/stop", protected.ThenFunc(app.timerStop))
+	router.Handler(http.MethodGet, "/api/v1/todos/:id/time-entries", todoViewer.ThenFunc(app.timeEntryList))
+	router.Handler(http.MethodPost, "/api/v1/todos/:id/time-entries", todoViewer.ThenFunc(app.timeEntryCreate))
+	router.Handler(http.MethodPut, "/api/v1/todos/:id/time-entries/:entryId", todoViewer.ThenFunc(app.timeEntryUpdate))
+	router.Handler(http.MethodDelete, "/api/v1/todos/:id/time-entries/:entryId", todoViewer.ThenFunc(app.timeEntryDelete))
+	// -- views over the todos, kept out of /todos where they would clash with :id
+	router.Handler(http.MethodGet, "/api/v1/matrix", protected.ThenFunc(app.todoMatrix))
+	router.Handler(http.MethodGet, "/api/v1/assigned", protected.ThenFunc(app.todoAssigned))
+	router.Handler(http.MethodGet, "/api/v1/next", protected.ThenFunc(app.todoNext))
+	router.Handler(http.MethodGet, "/api/v1/kanban", protected.ThenFunc(app.todoKanban))
+	router.Handler(http.MethodGet, "/api/v1/stats", protected.ThenFunc(app.todoStats))
+	router.Handler(http.MethodGet, "/api/v1/estimates", protected.ThenFunc(app.todoEstimates))
+	router.Handler(http.MethodGet, "/api/v1/timer", protected.ThenFunc(app.timerRunning))
+	router.Handler(http.MethodGet, "/api/v1/time-report", protected.ThenFunc(app.timeReport))
+	// -- trash
+	router.Handler(http.MethodGet, "/api/v1/trash", protected.ThenFunc(app.trashList))
+	router.Handler(http.MethodPost, "/api/v1/trash/:id/restore", todoEditor.ThenFunc(app.trashRestore))
+	router.Handler(http.MethodDelete, "/api/v1/trash/:id", todoEditor.ThenFunc(app.trashDelete))
+	// -- tags
+	router.Handler(http.MethodGet, "/api/v1/tags", protected.ThenFunc(app.tagList))
+	router.Handler(http.MethodPost, "/api/v1/tags", protected.ThenFunc(app.tagCreate))
+	router.Handler(http.MethodPut, "/api/v1/tags/:id", protected.ThenFunc(app.tagUpdate))
+	router.Handler(http.MethodDelete, "/api/v1/tags/:id", protected.ThenFunc(app.tagDelete))
+	// -- workflow states
+	router.Handler(http.MethodGet, "/api/v1/states", protected.ThenFunc(app.stateList))
+	router.Handler(http.MethodPost, "/api/v1/states", protected.ThenFunc(app.stateCreate))
+	router.Handler(http.MethodPut, "/api/v1/states/:id", protected.ThenFunc(app.stateUpdate))
+	router.Handler(http.MethodDelete, "/api/v1/states/:id", protected.ThenFunc(app.stateDelete))
+	// -- templates
+	router.Handler(http.MethodGet, "/api/v1/templates", protected.ThenFunc(app.templateList))
+	router.Handler(http.MethodPost, "/api/v1/templates", protected.ThenFunc(app.templateCreate))
+	router.Handler(http.MethodGet, "/api/v1/templates/:id", protected.ThenFunc(app.templateView))
+	router.Handler(http.MethodDelete, "/api/v1/templates/:id", protected.ThenFunc(app.templateDelete))
+	router.Handler(http.MethodPost, "/api/v1/templates/:id/instantiate", protected.ThenFunc(app.templateInstantiate))
+	// -- lists
+	router.Handler(http.MethodGet, "/api/v1/lists", protected.ThenFunc(app.listList))
+	router.Handler(http.MethodPost, "/api/v1/lists", protected.ThenFunc(app.listCreate))
+	router.Handler(http.MethodGet, "/api/v1/lists/:id", listViewer.ThenFunc(app.listView))
+	router.Handler(http.MethodPut, "/api/v1/lists/:id", listOwner.ThenFunc(app.listUpdate))
+	router.Handler(http.MethodDelete, "/api/v1/lists/:id", listOwner.ThenFunc(app.listDelete))
+	router.Handler(http.MethodGet, "/api/v1/lists/:id/members", listViewer.ThenFunc(app.memberList))
+	router.Handler(http.MethodPost, "/api/v1/lists/:id/members", listOwner.ThenFunc(app.memberAdd))
+	router.Handler(http.MethodPut, "/api/v1/lists/:id/members/:userId", listOwner.ThenFunc(app.memberUpdate))
+	// -- members can leave a list, owners can remove anyone but its creator
+	router.Handler(http.MethodDelete, "/api/v1/lists/:id/members/:userId", listViewer.ThenFunc(app.memberRemove))
+	// -- users
+	router.Handler(http.MethodGet, "/api/v1/users/me", protected.ThenFunc(app.userView))
+	router.Handler(http.MethodPatch, "/api/v1/users/me", protected.ThenFunc(app.userUpdate))
+
+	// legacy todo routes, kept as deprecated aliases of the /api/v1 routes
+	legacy := protected.Append(deprecated)
+	router.Handler(http.MethodGet, "/api", legacy.ThenFunc(app.home))
+	router.Handler(http.MethodGet, "/api/todo/view/:id", todoViewer.Append(deprecated).ThenFunc(app.todoView))
+	router.Handler(http.MethodPost, "/api/todo/create", legacy.ThenFunc(app.todoCreate)) // fixed path
+	router.Handler(http.MethodPut, "/api/todo/update/:id", todoEditor.Append(deprecated).ThenFunc(app.todoUpdate))
+	router.Handler(http.MethodPut, "/api/todo/toggle-status/:id", todoViewer.Append(deprecated).ThenFunc(app.todoToggleStatus))
+	router.Handler(http.MethodDelete, "/api/todo/delete/:id", todoEditor.Append(deprecated).ThenFunc(app.todoDelete))
+	router.Handler(http.MethodGet, "/api/todos/matrix", legacy.ThenFunc(app.todoMatrix))
 	// logout the user
 	router.Handler(http.MethodPost, "/api/user/logout", protected.ThenFunc(app.userLogout))
 	// Create a middleware chain containing our 'standard' middleware
 	// which will be used for every request our application receives.
-	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	standard := alice.New(requestID, app.recoverPanic, app.logRequest, secureHeaders)
 	// Return the 'standard' middleware chain followed by the servemux.
 	return standard.Then(router)
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// Input struct for snoozing a todo
+type SnoozeInput struct {
+	// tomorrow, next_week or an RFC 3339 time
+	Until string `json:"until"`
+	validator.Validator
+}
+
+// snoozeTime resolves until into the time a snooze ends. tomorrow is the
+// start of the next day and next_week the start of next Monday, in loc, the
+// time zone of the user. The zero time is returned for anything else that is
+// not an RFC 3339 time.
+func snoozeTime(until string, now time.Time, loc *time.Location) time.Time {
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	switch until {
+	case "tomorrow":
+		return today.AddDate(0, 0, 1)
+	case "next_week":
+		return today.AddDate(0, 0, 7-(int(today.Weekday())+6)%7)
+	}
+
+	t, err := time.Parse(time.RFC3339, until)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// readIncludeSnoozed reports whether the todo lists should also hold
+// the todos snoozed until a later time (?include_snoozed=true)
+func readIncludeSnoozed(r *http.Request) bool {
+	return readString(r.URL.Query(), "include_snoozed", "false") == "true"
+}
+
+// hide a todo from the default lists until a later time, it reappears
+// on its own once the time has passed
+func (app *application) todoSnooze(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	var input SnoozeInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	loc, err := app.userLocation(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	until := snoozeTime(input.Until, now, loc)
+	input.Validate()
+	if input.Valid() {
+		input.CheckField(!until.IsZero(), "until", "This field must be tomorrow, next_week or a time such as 2024-05-01T09:00:00Z")
+		input.CheckField(until.IsZero() || until.After(now), "until", "This field must be in the future")
+	}
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	app.snooze(w, r, id, &until)
+}
+
+// show a snoozed todo again
+func (app *application) todoWake(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	app.snooze(w, r, id, nil)
+}
+
+// snooze sets the end of the snooze of a todo the user can work on, or
+// clears it if until is nil
+func (app *application) snooze(w http.ResponseWriter, r *http.Request, id string, until *time.Time) {
+	userID := app.authenticatedUserID(r)
+	todo, err := app.todos.Get(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if !app.canWorkOn(r, todo) {
+		app.forbidden(w, r, "Viewers can only snooze the todos assigned to them")
+		return
+	}
+
+	err = app.todos.Snooze(userID, id, until)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if until != nil {
+		app.setFlash(r.Context(), "Todo has been snoozed.")
+	} else {
+		app.setFlash(r.Context(), "Todo is no longer snoozed.")
+	}
+	app.writeTodo(w, r, userID, id)
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// Input struct for creating and updating the states of a workflow
+type StateInput struct {
+	Name string `json:"name"`
+	// IDs of the states todos can move to from this one
+	Next []string `json:"next"`
+	validator.Validator
+}
+
+// Input struct for moving a todo to another state
+type TodoStateInput struct {
+	StateID string `json:"state_id"`
+	validator.Validator
+}
+
+// Response struct for returning a state of a workflow
+type StateResponse struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Position int       `json:"position"`
+	Initial  bool      `json:"initial"`
+	Final    bool      `json:"final"`
+	Next     []string  `json:"next"`
+	Created  time.Time `json:"created"`
+}
+
+// Response struct for returning a workflow, in order
+type StateListResponse struct {
+	States []StateResponse `json:"states"`
+}
+
+// Response struct for returning the todos in one state of a workflow
+type KanbanColumnResponse struct {
+	State StateResponse  `json:"state"`
+	Todos []TodoResponse `json:"todos"`
+}
+
+// Response struct for returning the kanban board of a workflow
+type KanbanResponse struct {
+	Columns []KanbanColumnResponse `json:"columns"`
+}
+
+// newStateResponse converts a state model into its JSON representation
+func newStateResponse(s *models.State) StateResponse {
+	return StateResponse{
+		ID:       s.ID,
+		Name:     s.Name,
+		Position: s.Position,
+		Initial:  s.Initial,
+		Final:    s.Final,
+		Next:     s.Next,
+		Created:  s.Created,
+	}
+}
+
+// list the states of the workflow of the user
+func (app *application) stateList(w http.ResponseWriter, r *http.Request) {
+	states, err := app.states.All(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	response := StateListResponse{States: make([]StateResponse, 0, len(states))}
+	for _, s := range states {
+		response.States = append(response.States, newStateResponse(s))
+	}
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// create, the state is added just before the final state
+func (app *application) stateCreate(w http.ResponseWriter, r *http.Request) {
+	var input StateInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	id := uuid.New().String()
+	err = app.states.Insert(userID, id, input.Name, input.Next)
+	if err != nil {
+		app.stateChangeError(w, r, err, &input)
+		return
+	}
+
+	app.writeState(w, r, http.StatusCreated, userID, id)
+}
+
+// update, the transitions of the state are replaced by next
+func (app *application) stateUpdate(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	var input StateInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	err = app.states.Update(userID, id, input.Name, input.Next)
+	if err != nil {
+		app.stateChangeError(w, r, err, &input)
+		return
+	}
+
+	app.writeState(w, r, http.StatusOK, userID, id)
+}
+
+// delete, only the states no todo is in can be deleted
+func (app *application) stateDelete(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	state, err := app.states.Get(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.states.Delete(userID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w, r)
+		case errors.Is(err, models.ErrFixedState):
+			app.errorResponse(w, r, http.StatusConflict, "The initial and final states cannot be deleted")
+		case errors.Is(err, models.ErrStateInUse):
+			app.errorResponse(w, r, http.StatusConflict, "Some todos are still in this state")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, newStateResponse(state))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// move a todo to another state of the workflow of its list, along one of
+// the transitions of its current state
+func (app *application) todoSetState(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	var input TodoStateInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	todo, err := app.todos.Get(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if !app.canWorkOn(r, todo) {
+		app.forbidden(w, r, "Viewers can only change the state of the todos assigned to them")
+		return
+	}
+
+	// reaching the final state completes the todo, and a recurring todo
+	// then gets its next occurrence
+	var next *models.Todo
+	if !todo.Status && todo.RRule != "" {
+		next, err = app.nextOccurrence(r, todo)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	inserted, err := app.todos.SetState(userID, id, input.StateID, next, readForce(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w, r)
+		case errors.Is(err, models.ErrUnknownState):
+			input.AddFieldError("state_id", "This field must be the ID of a state of the workflow of the list")
+			app.failedValidation(w, r, &input.Validator)
+		case errors.Is(err, models.ErrIllegalTransition):
+			app.errorResponse(w, r, http.StatusConflict, "The todo cannot move from its current state to this one")
+		case errors.Is(err, models.ErrBlocked):
+			app.blocked(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if inserted {
+		w.Header().Set("Location", "/api/v1/todos/"+next.ID)
+	}
+
+	app.setFlash(r.Context(), "Todo state has been updated.")
+	app.writeTodo(w, r, userID, id)
+}
+
+// the todos grouped by the states of a workflow: that of the user across
+// the lists they created, or with ?list= that of the creator of one list
+func (app *application) todoKanban(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
+	listID := readString(r.URL.Query(), "list", "")
+
+	owner := userID
+	if listID != "" {
+		if _, err := uuid.Parse(listID); err != nil {
+			v := &validator.Validator{}
+			v.AddFieldError("list", "This field must be the ID of a list")
+			app.failedValidation(w, r, v)
+			return
+		}
+
+		list, err := app.lists.Get(userID, listID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+		owner = list.UserID
+	}
+
+	columns, err := app.todos.Kanban(userID, owner, listID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	response := KanbanResponse{Columns: make([]KanbanColumnResponse, 0, len(columns))}
+	for _, c := range columns {
+		response.Columns = append(response.Columns, KanbanColumnResponse{
+			State: newStateResponse(c.State),
+			Todos: newTodoResponses(c.Todos),
+		})
+	}
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// stateChangeError sends the response for a failed change of a workflow
+func (app *application) stateChangeError(w http.ResponseWriter, r *http.Request, err error, input *StateInput) {
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.notFound(w, r)
+	case errors.Is(err, models.ErrDuplicateName):
+		app.duplicateName(w, r, &input.Validator)
+	case errors.Is(err, models.ErrUnknownState):
+		input.AddFieldError("next", "This field must only hold IDs of states of your workflow")
+		app.failedValidation(w, r, &input.Validator)
+	default:
+		app.serverError(w, r, err)
+	}
+}
+
+// writeState sends a state as read back from the database
+func (app *application) writeState(w http.ResponseWriter, r *http.Request, status int, userID, id string) {
+	state, err := app.states.Get(userID, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, status, newStateResponse(state))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// longest range of days the statistics can be computed over
+const maxStatsDays = 366
+
+// Query string of the statistics
+type statsQuery struct {
+	// first and last day of the range, at midnight in the time zone of the user
+	From time.Time
+	To   time.Time
+	validator.Validator
+}
+
+// Response struct for returning the number of todos completed on a day
+type DayCountResponse struct {
+	Date      string `json:"date"`
+	Completed int    `json:"completed"`
+}
+
+// Response struct for returning the statistics of the todos over a range of days
+type StatsResponse struct {
+	From            string             `json:"from"`
+	To              string             `json:"to"`
+	CompletedPerDay []DayCountResponse `json:"completed_per_day"`
+	Completed       int                `json:"completed"`
+	// null if no todo was completed over the range
+	AverageCompletionSeconds *float64 `json:"average_completion_seconds"`
+	CurrentStreak            int      `json:"current_streak"`
+	LongestStreak            int      `json:"longest_streak"`
+	// todos created over the range, still open or done
+	Open int `json:"open"`
+	Done int `json:"done"`
+}
+
+// productivity statistics, from and to are dates in the time zone of the
+// user and default to the last 30 days
+func (app *application) todoStats(w http.ResponseWriter, r *http.Request) {
+	loc, err := app.userLocation(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	q := readStatsQuery(r.URL.Query(), loc)
+	if !q.Valid() {
+		app.failedValidation(w, r, &q.Validator)
+		return
+	}
+
+	stats, err := app.todos.Stats(app.authenticatedUserID(r), q.From, q.To)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	response := StatsResponse{
+		From:            q.From.Format(time.DateOnly),
+		To:              q.To.Format(time.DateOnly),
+		CompletedPerDay: make([]DayCountResponse, 0, len(stats.CompletedPerDay)),
+		Completed:       stats.Completed,
+		CurrentStreak:   stats.CurrentStreak,
+		LongestStreak:   stats.LongestStreak,
+		Open:            stats.Open,
+		Done:            stats.Done,
+	}
+	for _, d := range stats.CompletedPerDay {
+		response.CompletedPerDay = append(response.CompletedPerDay, DayCountResponse{
+			Date:      d.Date.Format(time.DateOnly),
+			Completed: d.Count,
+		})
+	}
+	if stats.AverageCompletion != nil {
+		seconds := stats.AverageCompletion.Seconds()
+		response.AverageCompletionSeconds = &seconds
+	}
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// readStatsQuery reads the range of days of the query string, in the time
+// zone loc of the user. The range defaults to the last 30 days.
+func readStatsQuery(qs url.Values, loc *time.Location) *statsQuery {
+	q := &statsQuery{}
+	q.From = readDate(qs, "from", loc, &q.Validator)
+	q.To = readDate(qs, "to", loc, &q.Validator)
+	if !q.Valid() {
+		return q
+	}
+
+	if q.To.IsZero() {
+		now := time.Now().In(loc)
+		q.To = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	}
+	if q.From.IsZero() {
+		q.From = q.To.AddDate(0, 0, -29)
+	}
+
+	q.Validate()
+	return q
+}
+
+// readListParam returns the optional ?list= of the reports, which limits
+// them to the todos of one list
+func readListParam(qs url.Values, v *validator.Validator) string {
+	listID := readString(qs, "list", "")
+	if listID != "" {
+		_, err := uuid.Parse(listID)
+		v.CheckField(err == nil, "list", "This field must be the ID of a list")
+	}
+	return listID
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// Input struct for moving a todo under another parent, a null
+// parent_id moves the todo back to the top level
+type TodoParentInput struct {
+	ParentID *string `json:"parent_id"`
+	validator.Validator
+}
+
+// Response struct for returning a todo together with its subtasks
+type TodoTreeResponse struct {
+	TodoResponse
+	Subtasks []*TodoTreeResponse `json:"subtasks"`
+}
+
+// newTodoTreeResponse nests the todos returned by TodoModel.Tree under
+// their parents, todos[0] being the root
+func newTodoTreeResponse(todos []*models.Todo) *TodoTreeResponse {
+	nodes := make(map[string]*TodoTreeResponse, len(todos))
+	for _, t := range todos {
+		nodes[t.ID] = &TodoTreeResponse{
+			TodoResponse: newTodoResponse(t),
+			Subtasks:     []*TodoTreeResponse{},
+		}
+	}
+
+	// subtasks are in creation order, so siblings keep that order
+	for _, t := range todos[1:] {
+		if parent, ok := nodes[t.ParentID]; ok {
+			parent.Subtasks = append(parent.Subtasks, nodes[t.ID])
+		}
+	}
+
+	return nodes[todos[0].ID]
+}
+
+// renderNotes renders the notes of the whole tree with ?render=html
+func (tree *TodoTreeResponse) renderNotes(r *http.Request) error {
+	err := renderTodoNotes(r, &tree.TodoResponse)
+	if err != nil {
+		return err
+	}
+	for _, subtask := range tree.Subtasks {
+		err = subtask.renderNotes(r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCascade reports whether a status change should
+// be applied to the subtasks as well (?cascade=true)
+func readCascade(r *http.Request) bool {
+	return readString(r.URL.Query(), "cascade", "false") == "true"
+}
+
+// readForce reports whether a todo should be completed even
+// though it waits for open todos (?force=true)
+func readForce(r *http.Request) bool {
+	return readString(r.URL.Query(), "force", "false") == "true"
+}
+
+// create a subtask, it is added to the list of its parent
+func (app *application) subtaskCreate(w http.ResponseWriter, r *http.Request) {
+	parentID := readIDParam(r)
+	if parentID == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	var input TodoInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.estimateUnit, err = app.estimateUnit(r, input.Estimate)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	parent, err := app.todos.Get(userID, parentID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	todo := input.todo(uuid.New().String(), userID)
+	todo.ListID = parent.ListID
+	todo.ParentID = parent.ID
+	id, err := app.todos.Insert(todo)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	todo, err = app.todos.Get(userID, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.setFlash(r.Context(), "Subtask has been created.")
+
+	response := newTodoResponse(todo)
+	response.Flash = app.getFlash(r.Context())
+
+	err = encodeJSON(w, http.StatusCreated, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// move a todo under another parent, or back to the top level
+func (app *application) todoReparent(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	var input TodoParentInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	parentID := ""
+	if input.ParentID != nil {
+		parentID = *input.ParentID
+		_, err := uuid.Parse(parentID)
+		input.CheckField(err == nil, "parent_id", "This field must be null or the ID of one of your todos")
+	}
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	// make sure the todo itself exists, so that a missing
+	// parent can be reported as a field error
+	_, err = app.todos.Get(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.todos.Reparent(userID, id, parentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			input.AddFieldError("parent_id", "This field must be null or the ID of one of your todos")
+			app.failedValidation(w, r, &input.Validator)
+		case errors.Is(err, models.ErrCycle):
+			input.AddFieldError("parent_id", "A todo cannot be nested under itself or one of its subtasks")
+			app.failedValidation(w, r, &input.Validator)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.setFlash(r.Context(), "Todo has been moved.")
+	app.writeTodo(w, r, userID, id)
+}
+
+// a todo with all of its subtasks, nested
+func (app *application) todoTree(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	todos, err := app.todos.Tree(app.authenticatedUserID(r), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	response := newTodoTreeResponse(todos)
+	err = response.renderNotes(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// Input struct for creating and updating tags
+type TagInput struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	validator.Validator
+}
+
+// Response struct for returning tag data
+type TagResponse struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Color   string    `json:"color"`
+	Created time.Time `json:"created"`
+}
+
+// Response struct for returning a list of tags
+type TagListResponse struct {
+	Tags []TagResponse `json:"tags"`
+}
+
+// newTagResponse converts a tag model into its JSON representation
+func newTagResponse(t *models.Tag) TagResponse {
+	return TagResponse{
+		ID:      t.ID,
+		Name:    t.Name,
+		Color:   t.Color,
+		Created: t.Created,
+	}
+}
+
+// newTagResponses converts a list of tag models
+func newTagResponses(tags []*models.Tag) []TagResponse {
+	responses := make([]TagResponse, 0, len(tags))
+	for _, t := range tags {
+		responses = append(responses, newTagResponse(t))
+	}
+	return responses
+}
+
+// list
+func (app *application) tagList(w http.ResponseWriter, r *http.Request) {
+	tags, err := app.tags.All(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, TagListResponse{Tags: newTagResponses(tags)})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// create
+func (app *application) tagCreate(w http.ResponseWriter, r *http.Request) {
+	var input TagInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	tag := &models.Tag{
+		ID:     uuid.New().String(),
+		UserID: userID,
+		Name:   input.Name,
+		Color:  input.Color,
+	}
+
+	_, err = app.tags.Insert(tag)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateName) {
+			app.duplicateName(w, r, &input.Validator)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	tag, err = app.tags.Get(userID, tag.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, http.StatusCreated, newTagResponse(tag))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// update
+func (app *application) tagUpdate(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	var input TagInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	err = app.tags.Update(userID, &models.Tag{ID: id, Name: input.Name, Color: input.Color})
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w, r)
+		case errors.Is(err, models.ErrDuplicateName):
+			app.duplicateName(w, r, &input.Validator)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	tag, err := app.tags.Get(userID, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, newTagResponse(tag))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// delete, the tag is detached from all of its todos
+func (app *application) tagDelete(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	tag, err := app.tags.Get(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.tags.Delete(userID, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, newTagResponse(tag))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// attach a tag to a todo
+func (app *application) todoTagAttach(w http.ResponseWriter, r *http.Request) {
+	app.changeTodoTag(w, r, app.tags.Attach)
+}
+
+// detach a tag from a todo
+func (app *application) todoTagDetach(w http.ResponseWriter, r *http.Request) {
+	app.changeTodoTag(w, r, app.tags.Detach)
+}
+
+// changeTodoTag runs change with the todo and tag of the route,
+// then writes the updated todo
+func (app *application) changeTodoTag(w http.ResponseWriter, r *http.Request, change func(userID, todoID, tagID string) error) {
+	todoID := readIDParam(r)
+	tagID := readUUIDParam(r, "tagId")
+	if todoID == "" || tagID == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	err := change(userID, todoID, tagID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.writeTodo(w, r, userID, todoID)
+}
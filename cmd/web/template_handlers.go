@@ -0,0 +1,422 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// most todos a template can hold, subtasks included
+const maxTemplateItems = 100
+
+// Input struct for creating a template from existing todos, which are
+// copied with their subtasks
+type TemplateInput struct {
+	Name    string   `json:"name"`
+	TodoIDs []string `json:"todo_ids"`
+	validator.Validator
+}
+
+// Input struct for creating the todos of a template
+type InstantiateInput struct {
+	// list of the new todos, the inbox if empty
+	ListID string `json:"list_id"`
+	// time the offsets of the items count from, now if null
+	Start *time.Time `json:"start"`
+	validator.Validator
+}
+
+// Response struct for returning a todo of a template
+type TemplateItemResponse struct {
+	// index of the parent item, null for top-level items
+	Parent    *int   `json:"parent"`
+	Body      string `json:"body"`
+	Notes     string `json:"notes"`
+	Priority  string `json:"priority"`
+	Important bool   `json:"important"`
+	// dates relative to the start of an instantiation, null for none
+	StartOffsetSeconds *int64 `json:"start_offset_seconds"`
+	DueOffsetSeconds   *int64 `json:"due_offset_seconds"`
+}
+
+// Response struct for returning template data
+type TemplateResponse struct {
+	ID      string                 `json:"id"`
+	Name    string                 `json:"name"`
+	Created time.Time              `json:"created"`
+	Items   []TemplateItemResponse `json:"items"`
+}
+
+// Response struct for returning a list of templates
+type TemplateListResponse struct {
+	Templates []TemplateResponse `json:"templates"`
+}
+
+// Response struct for returning the todos created from a template
+type InstantiateResponse struct {
+	Todos []TodoResponse `json:"todos"`
+}
+
+// newTemplateResponse converts a template model into its JSON
+// representation
+func newTemplateResponse(t *models.Template) TemplateResponse {
+	response := TemplateResponse{
+		ID:      t.ID,
+		Name:    t.Name,
+		Created: t.Created,
+		Items:   make([]TemplateItemResponse, 0, len(t.Items)),
+	}
+	for _, item := range t.Items {
+		response.Items = append(response.Items, TemplateItemResponse{
+			Parent:             item.Parent,
+			Body:               item.Body,
+			Notes:              item.Notes,
+			Priority:           models.PriorityNames[item.Priority],
+			Important:          item.Important,
+			StartOffsetSeconds: durationSeconds(item.StartOffset),
+			DueOffsetSeconds:   durationSeconds(item.DueOffset),
+		})
+	}
+	return response
+}
+
+// durationSeconds converts an optional duration to whole seconds
+func durationSeconds(d *time.Duration) *int64 {
+	if d == nil {
+		return nil
+	}
+	seconds := int64(d.Seconds())
+	return &seconds
+}
+
+// list
+func (app *application) templateList(w http.ResponseWriter, r *http.Request) {
+	templates, err := app.templates.All(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	response := TemplateListResponse{Templates: make([]TemplateResponse, 0, len(templates))}
+	for _, t := range templates {
+		response.Templates = append(response.Templates, newTemplateResponse(t))
+	}
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// view
+func (app *application) templateView(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	template, err := app.templates.Get(app.authenticatedUserID(r), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, newTemplateResponse(template))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// create a template from todos the user can see, with their subtasks. Their
+// dates are kept relative to the earliest of them.
+func (app *application) templateCreate(w http.ResponseWriter, r *http.Request) {
+	var input TemplateInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	trees := make([][]*models.Todo, 0, len(input.TodoIDs))
+	for _, id := range input.TodoIDs {
+		todos, err := app.todos.Tree(userID, id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				input.AddFieldError("todo_ids", "This field must only hold IDs of todos you can see")
+				app.failedValidation(w, r, &input.Validator)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+		trees = append(trees, todos)
+	}
+
+	template := &models.Template{
+		ID:     uuid.New().String(),
+		UserID: userID,
+		Name:   input.Name,
+		Items:  templateItems(trees),
+	}
+	input.CheckField(len(template.Items) <= maxTemplateItems, "todo_ids", "A template cannot hold more than 100 todos")
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	_, err = app.templates.Insert(template)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateName) {
+			app.duplicateName(w, r, &input.Validator)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	template, err = app.templates.Get(userID, template.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, http.StatusCreated, newTemplateResponse(template))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// templateItems turns trees of todos, each root first, into the items of a
+// template, parents before their subtasks. A todo found in several trees
+// is kept once, under its parent if the parent is kept too. Deleted todos
+// are left out with their subtasks.
+func templateItems(trees [][]*models.Todo) []*models.TemplateItem {
+	byID := make(map[string]*models.Todo)
+	for _, tree := range trees {
+		for _, t := range tree {
+			byID[t.ID] = t
+		}
+	}
+	// whether t or one of the ancestors found with it is in the trash
+	deleted := func(t *models.Todo) bool {
+		for ; t != nil; t = byID[t.ParentID] {
+			if t.DeletedAt != nil {
+				return true
+			}
+		}
+		return false
+	}
+
+	selected := make(map[string]bool)
+	todos := []*models.Todo{}
+	for _, tree := range trees {
+		for _, t := range tree {
+			if selected[t.ID] || deleted(t) {
+				continue
+			}
+			selected[t.ID] = true
+			todos = append(todos, t)
+		}
+	}
+	children := make(map[string][]*models.Todo)
+	roots := []*models.Todo{}
+	for _, t := range todos {
+		if selected[t.ParentID] {
+			children[t.ParentID] = append(children[t.ParentID], t)
+		} else {
+			roots = append(roots, t)
+		}
+	}
+
+	// dates are kept relative to the earliest of them
+	var anchor time.Time
+	for _, t := range todos {
+		for _, d := range []*time.Time{t.StartAt, t.DueAt} {
+			if d != nil && (anchor.IsZero() || d.Before(anchor)) {
+				anchor = *d
+			}
+		}
+	}
+	offset := func(d *time.Time) *time.Duration {
+		if d == nil {
+			return nil
+		}
+		o := d.Sub(anchor)
+		return &o
+	}
+
+	items := []*models.TemplateItem{}
+	var visit func(t *models.Todo, parent *int)
+	visit = func(t *models.Todo, parent *int) {
+		items = append(items, &models.TemplateItem{
+			Parent:      parent,
+			Body:        t.Body,
+			Notes:       t.Notes,
+			Priority:    t.Priority,
+			Important:   t.Important,
+			StartOffset: offset(t.StartAt),
+			DueOffset:   offset(t.DueAt),
+		})
+		index := len(items) - 1
+		for _, child := range children[t.ID] {
+			visit(child, &index)
+		}
+	}
+	for _, root := range roots {
+		visit(root, nil)
+	}
+
+	return items
+}
+
+// create the todos of a template in a list the user can edit, all at once.
+// Their dates are the offsets of the items from ?start, or from now.
+func (app *application) templateInstantiate(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	var input InstantiateInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	template, err := app.templates.Get(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// todos created without a list go to the inbox
+	if input.ListID == "" {
+		inbox, err := app.lists.Inbox(userID, uuid.New().String())
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		input.ListID = inbox.ID
+	} else {
+		err = app.checkListID(&input.Validator, userID, input.ListID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !input.Valid() {
+			app.failedValidation(w, r, &input.Validator)
+			return
+		}
+	}
+
+	start := time.Now().UTC()
+	if input.Start != nil {
+		start = input.Start.UTC()
+	}
+	at := func(offset *time.Duration) *time.Time {
+		if offset == nil {
+			return nil
+		}
+		t := start.Add(*offset)
+		return &t
+	}
+
+	todos := make([]*models.Todo, 0, len(template.Items))
+	for _, item := range template.Items {
+		t := &models.Todo{
+			ID:        uuid.New().String(),
+			UserID:    userID,
+			ListID:    input.ListID,
+			Body:      item.Body,
+			Notes:     item.Notes,
+			Priority:  item.Priority,
+			Important: item.Important,
+			StartAt:   at(item.StartOffset),
+			DueAt:     at(item.DueOffset),
+		}
+		if item.Parent != nil {
+			t.ParentID = todos[*item.Parent].ID
+		}
+		todos = append(todos, t)
+	}
+
+	err = app.todos.InsertAll(todos)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	response := InstantiateResponse{Todos: make([]TodoResponse, 0, len(todos))}
+	for _, t := range todos {
+		t, err = app.todos.Get(userID, t.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		response.Todos = append(response.Todos, newTodoResponse(t))
+	}
+
+	app.setFlash(r.Context(), "Todos have been created from the template.")
+
+	err = encodeJSON(w, http.StatusCreated, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// delete
+func (app *application) templateDelete(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	template, err := app.templates.Get(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.templates.Delete(userID, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, newTemplateResponse(template))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// longest time entry which can be added by hand
+const maxTimeEntry = 24 * time.Hour
+
+// Input struct for adding and editing time entries by hand
+type TimeEntryInput struct {
+	StartedAt *time.Time `json:"started_at"`
+	// null for an entry whose timer is still running
+	StoppedAt *time.Time `json:"stopped_at"`
+	validator.Validator
+}
+
+// Response struct for returning a time entry
+type TimeEntryResponse struct {
+	ID        string     `json:"id"`
+	TodoID    string     `json:"todo_id"`
+	UserID    string     `json:"user_id"`
+	UserName  string     `json:"user_name"`
+	StartedAt time.Time  `json:"started_at"`
+	StoppedAt *time.Time `json:"stopped_at"`
+	// up to now for a running timer
+	DurationSeconds int64     `json:"duration_seconds"`
+	Created         time.Time `json:"created"`
+}
+
+// Response struct for returning the time entries on a todo, the latest first
+type TimeEntryListResponse struct {
+	Entries      []TimeEntryResponse `json:"entries"`
+	TotalSeconds int64               `json:"total_seconds"`
+}
+
+// Response struct for returning the running timer of the user
+type TimerResponse struct {
+	// null if no timer is running
+	Running *TimeEntryResponse `json:"running"`
+}
+
+// Response struct for returning the time tracked on a todo
+type TodoTimeResponse struct {
+	TodoID  string `json:"todo_id"`
+	Body    string `json:"body"`
+	Seconds int64  `json:"seconds"`
+}
+
+// Response struct for returning the time tracked on a day
+type DayTimeResponse struct {
+	Date    string `json:"date"`
+	Seconds int64  `json:"seconds"`
+}
+
+// Response struct for returning the time tracked over a range of days
+type TimeReportResponse struct {
+	From         string             `json:"from"`
+	To           string             `json:"to"`
+	TotalSeconds int64              `json:"total_seconds"`
+	PerTodo      []TodoTimeResponse `json:"per_todo"`
+	PerDay       []DayTimeResponse  `json:"per_day"`
+}
+
+// newTimeEntryResponse converts a time entry model into its JSON
+// representation
+func newTimeEntryResponse(e *models.TimeEntry) TimeEntryResponse {
+	return TimeEntryResponse{
+		ID:              e.ID,
+		TodoID:          e.TodoID,
+		UserID:          e.UserID,
+		UserName:        e.UserName,
+		StartedAt:       e.StartedAt,
+		StoppedAt:       e.StoppedAt,
+		DurationSeconds: int64(e.Duration().Seconds()),
+		Created:         e.Created,
+	}
+}
+
+// start a timer on a todo, the user must not have another one running
+func (app *application) timerStart(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := app.readWorkableTodo(w, r)
+	if !ok {
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	id := uuid.New().String()
+	err := app.timeEntries.Start(userID, todoID, id)
+	if err != nil {
+		app.timeEntryChangeError(w, r, err)
+		return
+	}
+
+	app.writeTimeEntry(w, r, http.StatusCreated, userID, todoID, id)
+}
+
+// stop the running timer of the user on a todo
+func (app *application) timerStop(w http.ResponseWriter, r *http.Request) {
+	todoID := readIDParam(r)
+	if todoID == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	entry, err := app.timeEntries.Stop(app.authenticatedUserID(r), todoID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoTimer) {
+			app.errorResponse(w, r, http.StatusConflict, "No timer of yours is running on this todo")
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, newTimeEntryResponse(entry))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// the running timer of the user, whichever todo it is on
+func (app *application) timerRunning(w http.ResponseWriter, r *http.Request) {
+	response := TimerResponse{}
+
+	entry, err := app.timeEntries.Running(app.authenticatedUserID(r))
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+	if entry != nil {
+		running := newTimeEntryResponse(entry)
+		response.Running = &running
+	}
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// list the time entries of all the members on a todo
+func (app *application) timeEntryList(w http.ResponseWriter, r *http.Request) {
+	todoID := readIDParam(r)
+	if todoID == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	entries, err := app.timeEntries.All(app.authenticatedUserID(r), todoID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	response := TimeEntryListResponse{Entries: make([]TimeEntryResponse, 0, len(entries))}
+	for _, e := range entries {
+		entry := newTimeEntryResponse(e)
+		response.Entries = append(response.Entries, entry)
+		response.TotalSeconds += entry.DurationSeconds
+	}
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// add a time entry to a todo by hand
+func (app *application) timeEntryCreate(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := app.readWorkableTodo(w, r)
+	if !ok {
+		return
+	}
+
+	var input TimeEntryInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	entry := &models.TimeEntry{
+		ID:        uuid.New().String(),
+		TodoID:    todoID,
+		StartedAt: input.StartedAt.UTC(),
+		StoppedAt: utcTimePtr(input.StoppedAt),
+	}
+
+	err = app.timeEntries.Insert(userID, entry)
+	if err != nil {
+		app.timeEntryChangeError(w, r, err)
+		return
+	}
+
+	app.writeTimeEntry(w, r, http.StatusCreated, userID, todoID, entry.ID)
+}
+
+// edit the times of a time entry, only the user who tracked it can
+func (app *application) timeEntryUpdate(w http.ResponseWriter, r *http.Request) {
+	entry, ok := app.readOwnTimeEntry(w, r)
+	if !ok {
+		return
+	}
+
+	var input TimeEntryInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	err = app.timeEntries.Update(userID, entry.TodoID, entry.ID, input.StartedAt.UTC(), utcTimePtr(input.StoppedAt))
+	if err != nil {
+		app.timeEntryChangeError(w, r, err)
+		return
+	}
+
+	app.writeTimeEntry(w, r, http.StatusOK, userID, entry.TodoID, entry.ID)
+}
+
+// delete a time entry, only the user who tracked it can
+func (app *application) timeEntryDelete(w http.ResponseWriter, r *http.Request) {
+	entry, ok := app.readOwnTimeEntry(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.timeEntries.Delete(app.authenticatedUserID(r), entry.TodoID, entry.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, newTimeEntryResponse(entry))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// the time tracked per todo and per day, from and to are dates in the time
+// zone of the user and default to the last 30 days. ?list= limits the
+// report to the todos of one list.
+func (app *application) timeReport(w http.ResponseWriter, r *http.Request) {
+	loc, err := app.userLocation(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	qs := r.URL.Query()
+	q := readStatsQuery(qs, loc)
+	listID := readListParam(qs, &q.Validator)
+	if !q.Valid() {
+		app.failedValidation(w, r, &q.Validator)
+		return
+	}
+
+	report, err := app.timeEntries.Report(app.authenticatedUserID(r), listID, q.From, q.To)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	response := TimeReportResponse{
+		From:         q.From.Format(time.DateOnly),
+		To:           q.To.Format(time.DateOnly),
+		TotalSeconds: int64(report.Total.Seconds()),
+		PerTodo:      make([]TodoTimeResponse, 0, len(report.PerTodo)),
+		PerDay:       make([]DayTimeResponse, 0, len(report.PerDay)),
+	}
+	for _, t := range report.PerTodo {
+		response.PerTodo = append(response.PerTodo, TodoTimeResponse{
+			TodoID:  t.TodoID,
+			Body:    t.Body,
+			Seconds: int64(t.Duration.Seconds()),
+		})
+	}
+	for _, d := range report.PerDay {
+		response.PerDay = append(response.PerDay, DayTimeResponse{
+			Date:    d.Date.Format(time.DateOnly),
+			Seconds: int64(d.Duration.Seconds()),
+		})
+	}
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// readWorkableTodo returns the ID of the todo of the route if the user can
+// track time on it. Otherwise it sends a 404 Not Found or 403 Forbidden
+// response and returns false.
+func (app *application) readWorkableTodo(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return "", false
+	}
+
+	todo, err := app.todos.Get(app.authenticatedUserID(r), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return "", false
+	}
+
+	if !app.canWorkOn(r, todo) {
+		app.forbidden(w, r, "Viewers can only track time on the todos assigned to them")
+		return "", false
+	}
+
+	return id, true
+}
+
+// readOwnTimeEntry returns the time entry of the route if the user tracked
+// it and can still track time on its todo. Otherwise it sends a 404 Not
+// Found or 403 Forbidden response and returns false.
+func (app *application) readOwnTimeEntry(w http.ResponseWriter, r *http.Request) (*models.TimeEntry, bool) {
+	todoID, ok := app.readWorkableTodo(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	id := readUUIDParam(r, "entryId")
+	if id == "" {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	userID := app.authenticatedUserID(r)
+	entry, err := app.timeEntries.Get(userID, todoID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if entry.UserID != userID {
+		app.forbidden(w, r, "Only the user who tracked a time entry can change it")
+		return nil, false
+	}
+
+	return entry, true
+}
+
+// timeEntryChangeError sends the response for a failed change of the time
+// entries of the user
+func (app *application) timeEntryChangeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.notFound(w, r)
+	case errors.Is(err, models.ErrTimerRunning):
+		app.errorResponse(w, r, http.StatusConflict, "Another timer of yours is already running, stop it first")
+	default:
+		app.serverError(w, r, err)
+	}
+}
+
+// writeTimeEntry sends a time entry as read back from the database
+func (app *application) writeTimeEntry(w http.ResponseWriter, r *http.Request, status int, userID, todoID, id string) {
+	entry, err := app.timeEntries.Get(userID, todoID, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, status, newTimeEntryResponse(entry))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// utcTimePtr returns t in UTC, or nil if t is nil
+func utcTimePtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"todo-backend.kweeuhree/internal/models"
+	"todo-backend.kweeuhree/internal/validator"
+)
+
+// Query string of the todo list
+type todoListQuery struct {
+	Filter models.TodoFilter
+	// one of today, overdue or this_week, resolved by applyDue
+	Due string
+	validator.Validator
+}
+
+// readTodoListQuery parses the filters, paging and sorting
+// parameters of the todo list of userID
+func readTodoListQuery(r *http.Request, userID string) *todoListQuery {
+	qs := r.URL.Query()
+	q := &todoListQuery{}
+
+	q.Filter.Limit = readInt(qs, "limit", 20, &q.Validator)
+	q.Filter.Cursor = readString(qs, "cursor", "")
+	q.Filter.ListID = readString(qs, "list", "")
+	if q.Filter.ListID != "" {
+		if _, err := uuid.Parse(q.Filter.ListID); err != nil {
+			q.AddFieldError("list", "This field must be the ID of a list")
+		}
+	}
+	// assigned_to=me for the todos assigned to the user, or the ID of a user
+	switch assignee := readString(qs, "assigned_to", ""); assignee {
+	case "":
+	case "me":
+		q.Filter.AssigneeID = userID
+	default:
+		if _, err := uuid.Parse(assignee); err != nil {
+			q.AddFieldError("assigned_to", "This field must be me or the ID of a user")
+		}
+		q.Filter.AssigneeID = assignee
+	}
+	// parent=root for top-level todos, or the ID of a todo for its subtasks
+	switch parent := readString(qs, "parent", ""); parent {
+	case "":
+	case "root":
+		q.Filter.TopLevel = true
+	default:
+		if _, err := uuid.Parse(parent); err != nil {
+			q.AddFieldError("parent", "This field must be root or the ID of a todo")
+		}
+		q.Filter.ParentID = parent
+	}
+	q.Filter.Sort = readString(qs, "sort", "-created")
+	q.Filter.CreatedAfter = readTime(qs, "created_after", &q.Validator)
+	q.Filter.CreatedBefore = readTime(qs, "created_before", &q.Validator)
+	q.Due = readString(qs, "due", "")
+	q.Filter.Tags = readCSV(qs, "tag")
+	q.Filter.IncludeSnoozed = readIncludeSnoozed(r)
+
+	switch readString(qs, "tag_match", "any") {
+	case "any":
+	case "all":
+		q.Filter.TagsMatchAll = true
+	default:
+		q.AddFieldError("tag_match", "This field must be any or all")
+	}
+
+	switch readString(qs, "status", "") {
+	case "":
+	case "open":
+		q.Filter.Status = new(bool)
+	case "done":
+		done := true
+		q.Filter.Status = &done
+	default:
+		q.AddFieldError("status", "This field must be open or done")
+	}
+
+	return q
+}
+
+// applyDue turns the due filter into a range of due dates. Days and weeks
+// (starting on Monday) are those of loc, the time zone of the user.
+func (q *todoListQuery) applyDue(now time.Time, loc *time.Location) {
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	switch q.Due {
+	case "today":
+		q.Filter.DueFrom = today.UTC()
+		q.Filter.DueTo = today.AddDate(0, 0, 1).UTC()
+	case "this_week":
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		q.Filter.DueFrom = monday.UTC()
+		q.Filter.DueTo = monday.AddDate(0, 0, 7).UTC()
+	case "overdue":
+		// only open todos can be overdue, Validate refuses status=done
+		q.Filter.DueTo = now.UTC()
+		if q.Filter.Status == nil {
+			q.Filter.Status = new(bool)
+		}
+	}
+}
@@ -1,46 +1,283 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
 	"log"
 	"net/http"
-	"strconv"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/julienschmidt/httprouter" // router
 	"todo-backend.kweeuhree/internal/models"
 	"todo-backend.kweeuhree/internal/validator"
 )
 
 // Input struct for creating and updating todos
 type TodoInput struct {
-	Body string `json:"body"`
+	Body      string     `json:"body"`
+	Notes     string     `json:"notes"`
+	StartAt   *time.Time `json:"start_at"`
+	DueAt     *time.Time `json:"due_at"`
+	Priority  string     `json:"priority"`
+	Important bool       `json:"important"`
+	// RFC 5545 recurrence rule, empty for todos which do not recur
+	RRule string `json:"rrule"`
+	// estimated effort in the estimate unit of the user, null for none
+	Estimate *int `json:"estimate"`
+	// only used on creation, todos are moved with their own route
+	ListID string `json:"list_id"`
+	// estimate unit of the user, read before validation if Estimate is set
+	estimateUnit string
 	validator.Validator
 }
 
+// todo builds the todo described by the input, as written by userID
+func (input *TodoInput) todo(id, userID string) *models.Todo {
+	t := &models.Todo{
+		ID:        id,
+		UserID:    userID,
+		Body:      input.Body,
+		Notes:     input.Notes,
+		StartAt:   input.StartAt,
+		DueAt:     input.DueAt,
+		Priority:  priorityValue(input.Priority),
+		Important: input.Important,
+		RRule:     canonicalRRule(input.RRule),
+		Estimate:  input.Estimate,
+	}
+	if input.Estimate != nil {
+		t.EstimateUnit = input.estimateUnit
+		t.EstimatedBy = userID
+	}
+	return t
+}
+
+// Input struct for partially updating todos, only the
+// fields present in the request body are changed
+type TodoPatchInput struct {
+	Body      *string             `json:"body"`
+	Notes     *string             `json:"notes"`
+	Status    *bool               `json:"status"`
+	StartAt   optional[time.Time] `json:"start_at"`
+	DueAt     optional[time.Time] `json:"due_at"`
+	Priority  *string             `json:"priority"`
+	Important *bool               `json:"important"`
+	RRule     *string             `json:"rrule"`
+	Estimate  optional[int]       `json:"estimate"`
+	// estimate unit of the user, read before validation if Estimate is set
+	estimateUnit string
+	validator.Validator
+}
+
+// statusOnly reports whether the patch changes nothing but the status
+func (input *TodoPatchInput) statusOnly() bool {
+	return input.Body == nil && input.Notes == nil && !input.StartAt.Set && !input.DueAt.Set &&
+		input.Priority == nil && input.Important == nil && input.RRule == nil && !input.Estimate.Set
+}
+
+// apply copies the fields present in the patch onto t
+func (input *TodoPatchInput) apply(t *models.Todo) {
+	if input.Body != nil {
+		t.Body = *input.Body
+	}
+	if input.Notes != nil {
+		t.Notes = *input.Notes
+	}
+	if input.StartAt.Set {
+		t.StartAt = input.StartAt.Value
+	}
+	if input.DueAt.Set {
+		t.DueAt = input.DueAt.Value
+	}
+	if input.Priority != nil {
+		t.Priority = priorityValue(*input.Priority)
+	}
+	if input.Important != nil {
+		t.Important = *input.Important
+	}
+	if input.RRule != nil {
+		t.RRule = canonicalRRule(*input.RRule)
+	}
+	if input.Estimate.Set {
+		t.Estimate = input.Estimate.Value
+		t.EstimateUnit = ""
+		if t.Estimate != nil {
+			t.EstimateUnit = input.estimateUnit
+		}
+	}
+}
+
+// priorityValue returns the value of a validated priority name,
+// an empty name means no priority
+func priorityValue(name string) int {
+	for i, n := range models.PriorityNames {
+		if n == name {
+			return i
+		}
+	}
+	return models.PriorityNone
+}
+
 // Response struct for returning todo data
 type TodoResponse struct {
 	ID    string `json:"id"`
 	Body  string `json:"body"`
-	Flash string
+	Notes string `json:"notes"`
+	// notes rendered as sanitized HTML, only set with ?render=html
+	NotesHTML string        `json:"notes_html,omitempty"`
+	Status    bool          `json:"status"`
+	StateID   *string       `json:"state_id"`
+	State     *string       `json:"state"`
+	Created   time.Time     `json:"created"`
+	StartAt   *time.Time    `json:"start_at"`
+	DueAt     *time.Time    `json:"due_at"`
+	Priority  string        `json:"priority"`
+	Important bool          `json:"important"`
+	RRule     string        `json:"rrule"`
+	Position  string        `json:"position"`
+	ListID    string        `json:"list_id"`
+	Tags      []TagResponse `json:"tags"`
+	// null for unassigned todos
+	AssigneeID   *string `json:"assignee_id"`
+	AssigneeName *string `json:"assignee_name"`
+	// null for top-level todos
+	ParentID          *string `json:"parent_id"`
+	CompletedChildren int     `json:"completed_children"`
+	TotalChildren     int     `json:"total_children"`
+	CommentCount      int     `json:"comment_count"`
+	// only set for done todos
+	CompletedAt *time.Time `json:"completed_at"`
+	// null for todos without an estimate, given in the unit of the member
+	// who estimated it
+	Estimate     *int    `json:"estimate"`
+	EstimateUnit *string `json:"estimate_unit"`
+	// null for todos which are not snoozed
+	HiddenUntil *time.Time `json:"hidden_until"`
+	// only set for todos in the trash
+	DeletedAt *time.Time `json:"deleted_at"`
+	Flash     string     `json:"Flash,omitempty"`
+}
+
+// Response struct for returning a list of todos, NextCursor
+// is empty once the last page has been reached
+type TodoListResponse struct {
+	Todos      []TodoResponse `json:"todos"`
+	NextCursor string         `json:"next_cursor"`
 }
 
+// newTodoResponse converts a todo model into its JSON representation
+func newTodoResponse(t *models.Todo) TodoResponse {
+	response := TodoResponse{
+		ID:        t.ID,
+		Body:      t.Body,
+		Notes:     t.Notes,
+		Status:    t.Status,
+		Created:   t.Created,
+		StartAt:   t.StartAt,
+		DueAt:     t.DueAt,
+		Priority:  models.PriorityNames[t.Priority],
+		Important: t.Important,
+		RRule:     t.RRule,
+		Position:  t.Position,
+		ListID:    t.ListID,
+		Tags:      newTagResponses(t.Tags),
+
+		CompletedChildren: t.CompletedChildren,
+		TotalChildren:     t.TotalChildren,
+		CommentCount:      t.CommentCount,
+		CompletedAt:       t.CompletedAt,
+		HiddenUntil:       t.HiddenUntil,
+		DeletedAt:         t.DeletedAt,
+	}
+	if t.ParentID != "" {
+		response.ParentID = &t.ParentID
+	}
+	if t.StateID != "" {
+		response.StateID = &t.StateID
+		response.State = &t.State
+	}
+	if t.AssigneeID != "" {
+		response.AssigneeID = &t.AssigneeID
+		response.AssigneeName = &t.AssigneeName
+	}
+	if t.Estimate != nil {
+		response.Estimate = t.Estimate
+		response.EstimateUnit = &t.EstimateUnit
+	}
+	return response
+}
+
+// newTodoResponses converts a list of todo models
+func newTodoResponses(todos []*models.Todo) []TodoResponse {
+	responses := make([]TodoResponse, 0, len(todos))
+	for _, t := range todos {
+		responses = append(responses, newTodoResponse(t))
+	}
+	return responses
+}
+
+// legacy list of todos, kept for the deprecated /api route
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	todos, err := app.todos.All()
+	todos, err := app.todos.All(app.authenticatedUserID(r), readIncludeSnoozed(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Write the todos to the response as a bare JSON array
+	err = encodeJSON(w, http.StatusOK, newTodoResponses(todos))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// list
+func (app *application) todoList(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
+
+	query := readTodoListQuery(r, userID)
+	query.Validate()
+	if !query.Valid() {
+		app.failedValidation(w, r, &query.Validator)
+		return
+	}
+
+	// due dates are matched against the calendar of the user
+	if query.Due != "" {
+		loc, err := app.userLocation(r)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		query.applyDue(time.Now(), loc)
+	}
+
+	todos, nextCursor, err := app.todos.List(userID, query.Filter)
 	if err != nil {
-		app.serverError(w, err)
+		if errors.Is(err, models.ErrInvalidCursor) {
+			query.AddFieldError("cursor", "This field must be a cursor returned by a previous page with the same sort")
+			app.failedValidation(w, r, &query.Validator)
+		} else {
+			app.serverError(w, r, err)
+		}
 		return
 	}
 
-	// Set the Content-Type header to application/json if you are sending JSON
-	w.Header().Set("Content-Type", "application/json")
+	response := TodoListResponse{
+		Todos:      newTodoResponses(todos),
+		NextCursor: nextCursor,
+	}
+	for i := range response.Todos {
+		err = renderTodoNotes(r, &response.Todos[i])
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
 
-	// Write the todos to the response as JSON
-	err = json.NewEncoder(w).Encode(todos)
+	err = encodeJSON(w, http.StatusOK, response)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 }
@@ -48,30 +285,36 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 // read
 func (app *application) todoView(w http.ResponseWriter, r *http.Request) {
 	// Get the value of the "id" named parameter
-	params := httprouter.ParamsFromContext(r.Context())
-	id, err := strconv.Atoi(params.ByName("id"))
+	id := readIDParam(r)
 
-	// return a 404 Not Found in case of invalid id or error
-	if err != nil || id < 1 {
-		app.notFound(w)
+	// return a 404 Not Found in case of invalid id
+	if id == "" {
+		app.notFound(w, r)
 		return
 	}
 
-	todo, err := app.todos.Get(id)
-
+	todo, err := app.todos.Get(app.authenticatedUserID(r), id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
+			app.notFound(w, r)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Todo successfully added!")
+	response := newTodoResponse(todo)
+	err = renderTodoNotes(r, &response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
-	// write the todo data as a plain-text HTTP response body
-	fmt.Fprintf(w, "%+v", todo)
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 }
 
 // create
@@ -79,42 +322,80 @@ func (app *application) todoCreate(w http.ResponseWriter, r *http.Request) {
 
 	// Decode the JSON body into the input struct
 	var input TodoInput
-	err := decodeJSON(w, r, &input)
+	err := app.decodeJSON(w, r, &input)
 	if err != nil {
 		return
 	}
 
 	log.Printf("Received input.Body: %s", input.Body)
 
+	input.estimateUnit, err = app.estimateUnit(r, input.Estimate)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// validate input
 	input.Validate()
 	if !input.Valid() {
-		encodeJSON(w, http.StatusBadRequest, input.FieldErrors)
+		app.failedValidation(w, r, &input.Validator)
 		return
 	}
 
+	userID := app.authenticatedUserID(r)
+
+	// todos created without a list go to the inbox
+	if input.ListID == "" {
+		inbox, err := app.lists.Inbox(userID, uuid.New().String())
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		input.ListID = inbox.ID
+	} else {
+		err = app.checkListID(&input.Validator, userID, input.ListID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !input.Valid() {
+			app.failedValidation(w, r, &input.Validator)
+			return
+		}
+	}
+
 	newId := uuid.New().String()
 
-	// Insert the new todo using the ID and body
-	id, err := app.todos.Insert(newId, input.Body)
+	// Insert the new todo using the ID and input fields
+	todo := input.todo(newId, userID)
+	todo.ListID = input.ListID
+	id, err := app.todos.Insert(todo)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
+		return
+	}
+
+	todo, err = app.todos.Get(userID, id)
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 
 	app.setFlash(r.Context(), "Todo has been created.")
 
-	// Create a response that includes both ID and body
-	response := TodoResponse{
-		ID:    id,
-		Body:  input.Body,
-		Flash: app.getFlash(r.Context()),
+	// Create a response that includes the stored todo
+	response := newTodoResponse(todo)
+	response.Flash = app.getFlash(r.Context())
+	err = renderTodoNotes(r, &response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
 	// Write the response struct to the response as JSON
-	err = encodeJSON(w, http.StatusOK, response)
+	err = encodeJSON(w, http.StatusCreated, response)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 }
@@ -124,19 +405,18 @@ func (app *application) todoUpdate(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Attempting update...")
 
 	// Get the value of the "id" named parameter
-	params := httprouter.ParamsFromContext(r.Context())
-	id := params.ByName("id")
+	id := readIDParam(r)
 	log.Printf("Current todo id: %s", id)
 
 	if id == "" {
-		app.notFound(w)
+		app.notFound(w, r)
 		log.Printf("Exiting due to invalid id")
 		return
 	}
 
 	// Decode the JSON body into the input struct
 	var input TodoInput
-	err := decodeJSON(w, r, &input)
+	err := app.decodeJSON(w, r, &input)
 	if err != nil {
 		log.Printf("Exiting after decoding attempt...")
 		log.Printf("Error message %s", err)
@@ -145,83 +425,290 @@ func (app *application) todoUpdate(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("Received input.Body: %s", input.Body)
 
+	input.estimateUnit, err = app.estimateUnit(r, input.Estimate)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// validate input
 	input.Validate()
 	if !input.Valid() {
-		encodeJSON(w, http.StatusBadRequest, input.FieldErrors)
+		app.failedValidation(w, r, &input.Validator)
 		return
 	}
 
-	// Update the new todo using the ID and body
-	err = app.todos.Put(id, input.Body)
+	userID := app.authenticatedUserID(r)
+
+	// Update the todo using the ID and input fields
+	err = app.todos.Update(userID, input.todo(id, userID))
 	if err != nil {
-		app.serverError(w, err)
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
 		return
 	}
 
 	app.setFlash(r.Context(), "Todo has been updated.")
+	app.writeTodo(w, r, userID, id)
+}
 
-	// Create a response that includes both ID and body
-	response := TodoResponse{
-		ID:    id,
-		Body:  input.Body,
-		Flash: app.getFlash(r.Context()),
+// partial update
+func (app *application) todoPatch(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
 	}
 
-	// Write the response struct to the response as JSON
-	err = encodeJSON(w, http.StatusOK, response)
+	var input TodoPatchInput
+	err := app.decodeJSON(w, r, &input)
+	if err != nil {
+		return
+	}
+
+	input.estimateUnit, err = app.estimateUnit(r, input.Estimate.Value)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	input.Validate()
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	// Load the todo so that the patch can be applied on top of it
+	todo, err := app.todos.Get(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// viewers can only change the status of the todos assigned to them
+	statusOnly := input.statusOnly() && !readCascade(r)
+	if !models.RoleAtLeast(app.listRole(r), models.RoleEditor) && !(statusOnly && app.canWorkOn(r, todo)) {
+		app.forbidden(w, r, "Viewers can only change the status of the todos assigned to them")
+		return
+	}
+
+	// the schedule is validated once merged with the stored values
+	input.apply(todo)
+	validateSchedule(&input.Validator, todo.StartAt, todo.DueAt)
+	if !input.Valid() {
+		app.failedValidation(w, r, &input.Validator)
+		return
+	}
+
+	// the fields and the status are saved together, a blocked todo is
+	// left untouched. Completing a recurring todo creates its next occurrence.
+	completing := input.Status != nil && *input.Status && !todo.Status
+	switch {
+	case input.Status == nil:
+		err = app.todos.Update(userID, todo)
+	case input.statusOnly() && completing && todo.RRule != "":
+		err = app.completeRecurring(w, r, todo, readCascade(r), readForce(r))
+	case input.statusOnly():
+		err = app.todos.SetStatus(userID, id, *input.Status, readCascade(r), readForce(r))
+	default:
+		var next *models.Todo
+		if completing && todo.RRule != "" {
+			next, err = app.nextOccurrence(r, todo)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+		}
+		err = app.todos.UpdateStatus(userID, todo, *input.Status, next, readCascade(r), readForce(r))
+		if err == nil && next != nil {
+			w.Header().Set("Location", "/api/v1/todos/"+next.ID)
+		}
+	}
 	if err != nil {
-		app.serverError(w, err)
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w, r)
+		case errors.Is(err, models.ErrBlocked):
+			app.blocked(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
 		return
 	}
+
+	app.setFlash(r.Context(), "Todo has been updated.")
+	app.writeTodo(w, r, userID, id)
 }
 
 func (app *application) todoToggleStatus(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Attempting status toggle...")
 
 	// Get the value of the "id" named parameter
-	params := httprouter.ParamsFromContext(r.Context())
-	id := params.ByName("id")
+	id := readIDParam(r)
 	log.Printf("Current todo id: %s", id)
 
 	if id == "" {
-		app.notFound(w)
+		app.notFound(w, r)
 		log.Printf("Exiting due to invalid id")
 		return
 	}
 
-	// Delete the todo using the ID
-	err := app.todos.Toggle(id)
+	userID := app.authenticatedUserID(r)
+
+	todo, err := app.todos.Get(userID, id)
 	if err != nil {
-		app.serverError(w, err)
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// viewers can only toggle the todos assigned to them, without their subtasks
+	if !app.canWorkOn(r, todo) || (readCascade(r) && !models.RoleAtLeast(app.listRole(r), models.RoleEditor)) {
+		app.forbidden(w, r, "Viewers can only change the status of the todos assigned to them")
+		return
+	}
+
+	// Toggle the todo status using the ID, with ?cascade=true the subtasks
+	// get the new status as well. Completing a recurring todo creates its
+	// next occurrence instead of leaving the series done. A todo waiting
+	// for open todos is only completed with ?force=true.
+	if !todo.Status && todo.RRule != "" {
+		err = app.completeRecurring(w, r, todo, readCascade(r), readForce(r))
+	} else {
+		err = app.todos.Toggle(userID, id, readCascade(r), readForce(r))
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w, r)
+		case errors.Is(err, models.ErrBlocked):
+			app.blocked(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
 		return
 	}
+
+	app.setFlash(r.Context(), "Todo status has been updated.")
+	app.writeTodo(w, r, userID, id)
 }
 
-// delete
+// delete, the todo is moved to the trash
 func (app *application) todoDelete(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Attempting deletion...")
-	// Set the Content-Type header to application/json
-	// w.Header().Set("Content-Type", "application/json")
 
 	// Get the value of the "id" named parameter
-	params := httprouter.ParamsFromContext(r.Context())
-	id := params.ByName("id")
+	id := readIDParam(r)
 	log.Printf("Current todo id: %s", id)
 
 	if id == "" {
-		app.notFound(w)
+		app.notFound(w, r)
 		log.Printf("Exiting due to invalid id")
 		return
 	}
 
-	// Delete the todo using the ID
-	err := app.todos.Delete(id)
+	userID := app.authenticatedUserID(r)
+
+	// Fetch the todo first so that it can be echoed back once deleted
+	todo, err := app.todos.Get(userID, id)
 	if err != nil {
-		app.serverError(w, err)
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
 		return
-	} else {
-		json.NewEncoder(w).Encode("Deleted successfully!")
+	}
+
+	// Move the todo to the trash using the ID
+	err = app.todos.Delete(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.setFlash(r.Context(), "Todo has been moved to the trash.")
+
+	response := newTodoResponse(todo)
+	response.Flash = app.getFlash(r.Context())
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// writeTodo re-reads a todo after a change and writes it as JSON,
+// together with the pending flash message
+func (app *application) writeTodo(w http.ResponseWriter, r *http.Request, userID, id string) {
+	todo, err := app.todos.Get(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	response := newTodoResponse(todo)
+	response.Flash = app.getFlash(r.Context())
+	err = renderTodoNotes(r, &response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// Response struct for returning the Eisenhower matrix
+type MatrixResponse struct {
+	DoFirst   []TodoResponse `json:"do_first"`
+	Schedule  []TodoResponse `json:"schedule"`
+	Delegate  []TodoResponse `json:"delegate"`
+	Eliminate []TodoResponse `json:"eliminate"`
+}
+
+// open todos bucketed by urgency (priority) and importance
+func (app *application) todoMatrix(w http.ResponseWriter, r *http.Request) {
+	matrix, err := app.todos.Matrix(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	response := MatrixResponse{
+		DoFirst:   newTodoResponses(matrix.DoFirst),
+		Schedule:  newTodoResponses(matrix.Schedule),
+		Delegate:  newTodoResponses(matrix.Delegate),
+		Eliminate: newTodoResponses(matrix.Eliminate),
+	}
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 }
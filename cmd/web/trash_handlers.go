@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"todo-backend.kweeuhree/internal/models"
+)
+
+// Response struct for returning the trash
+type TrashResponse struct {
+	Todos []TodoResponse `json:"todos"`
+}
+
+// list the trashed todos
+func (app *application) trashList(w http.ResponseWriter, r *http.Request) {
+	todos, err := app.todos.Trash(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = encodeJSON(w, http.StatusOK, TrashResponse{Todos: newTodoResponses(todos)})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
+// restore a trashed todo with its subtasks
+func (app *application) trashRestore(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	err := app.todos.Restore(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.setFlash(r.Context(), "Todo has been restored.")
+	app.writeTodo(w, r, userID, id)
+}
+
+// permanently delete a trashed todo with its subtasks
+func (app *application) trashDelete(w http.ResponseWriter, r *http.Request) {
+	id := readIDParam(r)
+	if id == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	// Fetch the todo first so that it can be echoed back once deleted
+	todo, err := app.todos.GetDeleted(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// the attachments go with the todo and its subtasks
+	checksums, err := app.attachments.TreeChecksums(userID, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.todos.Purge(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.removeContents(checksums...)
+
+	app.setFlash(r.Context(), "Todo has been deleted permanently.")
+
+	response := newTodoResponse(todo)
+	response.Flash = app.getFlash(r.Context())
+
+	err = encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
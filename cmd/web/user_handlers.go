@@ -1,11 +1,11 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid" // router
 	"todo-backend.kweeuhree/internal/models"
@@ -17,7 +17,16 @@ type userSignUpInput struct {
 	Name                string `form:"name"`
 	Email               string `form:"email"`
 	Password            string `form:"password"`
-	validator.Validator `form: "-"`
+	Timezone            string `form:"timezone"`
+	validator.Validator `form:"-"`
+}
+
+// userUpdateInput struct for changing the profile of the current user
+type userUpdateInput struct {
+	Name                *string `json:"name"`
+	Timezone            *string `json:"timezone"`
+	EstimateUnit        *string `json:"estimate_unit"`
+	validator.Validator `json:"-"`
 }
 
 type UserResponse struct {
@@ -26,10 +35,21 @@ type UserResponse struct {
 	Flash string
 }
 
+// UserProfileResponse struct for returning the current user
+type UserProfileResponse struct {
+	Uuid     string    `json:"uuid"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Timezone string    `json:"timezone"`
+	Created  time.Time `json:"created"`
+	// unit of the estimates the user gives todos, minutes or points
+	EstimateUnit string `json:"estimate_unit"`
+}
+
 type userLoginInput struct {
-	Email               string `form: "email"`
-	Password            string `form: "password"`
-	validator.Validator `form: "-" `
+	Email               string `form:"email"`
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
 }
 
 // user authentication routes
@@ -40,7 +60,7 @@ func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	var form userSignUpInput
 
 	// parse the form data into the struct
-	err := decodeJSON(w, r, &form)
+	err := app.decodeJSON(w, r, &form)
 	if err != nil {
 		return
 	}
@@ -53,13 +73,12 @@ func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	// form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
 	// form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
 	// form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	if form.Timezone == "" {
+		form.Timezone = models.DefaultTimezone
+	}
 	form.Validate()
 	if !form.Valid() {
-		err := encodeJSON(w, http.StatusOK, form.FieldErrors)
-		if err != nil {
-			// app.serverError(w, err)
-			json.NewEncoder(w).Encode(err)
-		}
+		app.failedValidation(w, r, &form.Validator)
 		return
 	}
 
@@ -67,19 +86,35 @@ func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 
 	// Try to create a new user record in the database. If the email already
 	// exists then add an error message to the form and re-display it.
-	err = app.users.Insert(newId, form.Name, form.Email, form.Password)
+	err = app.users.Insert(newId, form.Name, form.Email, form.Password, form.Timezone)
 
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldError("email", "Email address is already in use")
 			app.errorLog.Printf("Failed adding user to database: %s", err)
-			json.NewEncoder(w).Encode(form.FieldErrors)
+			p := newProblem(r, http.StatusConflict, problemTypeDuplicateEmail, "Email address is already in use")
+			writeProblem(w, p.withValidator(&form.Validator))
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	// every user starts with an inbox, which receives the todos
+	// created without a list
+	_, err = app.lists.Inbox(newId, uuid.New().String())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// and with the default workflow for the todos of their lists
+	err = app.states.Defaults(newId)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	app.setFlash(r.Context(), "Your signup was successful. Please log in.")
 
 	// Create a response that includes both ID and body
@@ -92,7 +127,7 @@ func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	// Write the response struct to the response as JSON
 	err = encodeJSON(w, http.StatusOK, response)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -105,8 +140,7 @@ func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 
 	// Decode the form data into the userLoginInput struct
 	var form userLoginInput
-	if err := decodeJSON(w, r, &form); err != nil {
-		app.clientError(w, http.StatusBadRequest)
+	if err := app.decodeJSON(w, r, &form); err != nil {
 		return
 	}
 
@@ -115,10 +149,7 @@ func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 	// Validate input
 	form.Validate()
 	if !form.Valid() {
-		if err := encodeJSON(w, http.StatusOK, form.FieldErrors); err != nil {
-			app.serverError(w, err)
-			return
-		}
+		app.failedValidation(w, r, &form.Validator)
 		return
 	}
 
@@ -127,19 +158,17 @@ func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.AddNonFieldError("Email or password is incorrect")
-			if encodeErr := encodeJSON(w, http.StatusUnauthorized, form.FieldErrors); encodeErr != nil {
-				app.serverError(w, encodeErr)
-				return
-			}
+			p := newProblem(r, http.StatusUnauthorized, problemTypeInvalidCredentials, "Email or password is incorrect")
+			writeProblem(w, p.withValidator(&form.Validator))
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
 	// Renew session token
 	if err := app.sessionManager.RenewToken(r.Context()); err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -156,7 +185,7 @@ func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 
 	// Write response
 	if err := encodeJSON(w, http.StatusOK, response); err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -170,7 +199,7 @@ func (app *application) userLogout(w http.ResponseWriter, r *http.Request) {
 	// change session ID
 	err := app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -186,10 +215,86 @@ func (app *application) userLogout(w http.ResponseWriter, r *http.Request) {
 	// Write the response struct to the response as JSON
 	err = encodeJSON(w, http.StatusOK, response)
 	if err != nil {
-		app.serverError(w, err)
-		json.NewEncoder(w).Encode(err)
+		app.serverError(w, r, err)
 		return
 	}
 
 	fmt.Println(w, "Logged out the user")
 }
+
+// return the profile of the authenticated user
+func (app *application) userView(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.writeUser(w, r, user)
+}
+
+// change the name, time zone and/or estimate unit of the authenticated user
+func (app *application) userUpdate(w http.ResponseWriter, r *http.Request) {
+	var form userUpdateInput
+	err := app.decodeJSON(w, r, &form)
+	if err != nil {
+		return
+	}
+
+	form.Validate()
+	if !form.Valid() {
+		app.failedValidation(w, r, &form.Validator)
+		return
+	}
+
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if form.Name != nil {
+		user.Name = *form.Name
+	}
+	if form.Timezone != nil {
+		user.Timezone = *form.Timezone
+	}
+	if form.EstimateUnit != nil {
+		user.EstimateUnit = *form.EstimateUnit
+	}
+
+	err = app.users.Update(user)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.writeUser(w, r, user)
+}
+
+// writeUser writes the profile of a user as JSON
+func (app *application) writeUser(w http.ResponseWriter, r *http.Request, user *models.User) {
+	response := UserProfileResponse{
+		Uuid:     user.Uuid,
+		Name:     user.Name,
+		Email:    user.Email,
+		Timezone: user.Timezone,
+		Created:  user.Created,
+
+		EstimateUnit: user.EstimateUnit,
+	}
+
+	err := encodeJSON(w, http.StatusOK, response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+)
+
+// assign a todo of a list userID is an editor of to assigneeID, who must be
+// a member of that list as well. ErrNotMember is returned if they are not.
+func (m *TodoModel) Assign(userID, id, assigneeID string) error {
+	return m.setAssignee(userID, id, sql.NullString{String: assigneeID, Valid: true})
+}
+
+// remove the assignee of a todo of a list userID is an editor of
+func (m *TodoModel) Unassign(userID, id string) error {
+	return m.setAssignee(userID, id, sql.NullString{})
+}
+
+func (m *TodoModel) setAssignee(userID, id string, assigneeID sql.NullString) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	rr, err := trackRevisions(tx, userID, ActionAssign, id)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE todos SET assignee_id = ?
+	WHERE id = ? AND ` + writableTodo + ` AND deleted_at IS NULL`
+	args := []any{assigneeID, id, userID}
+	if assigneeID.Valid {
+		stmt += ` AND ` + memberOf("todos.list_id", RoleViewer)
+		args = append(args, assigneeID.String)
+	}
+
+	result, err := tx.Exec(stmt, args...)
+	if err != nil {
+		log.Printf("Error while assigning a todo %s", err)
+		return err
+	}
+	err = checkRowsAffected(result)
+	if err != nil {
+		if assigneeID.Valid {
+			return m.checkAssignee(tx, userID, id)
+		}
+		return err
+	}
+
+	err = rr.record()
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkAssignee tells why an assignment matched no row: ErrNotMember if the
+// todo could be changed by userID, ErrNoRecord otherwise
+func (m *TodoModel) checkAssignee(tx *sql.Tx, userID, id string) error {
+	var writable bool
+	stmt := `SELECT EXISTS(SELECT true FROM todos WHERE id = ? AND ` + writableTodo + ` AND deleted_at IS NULL)`
+	err := tx.QueryRow(stmt, id, userID).Scan(&writable)
+	if err != nil {
+		return err
+	}
+	if writable {
+		return ErrNotMember
+	}
+	return ErrNoRecord
+}
+
+// return the open todos assigned to userID in the lists they are a member
+// of, those due first at the top
+func (m *TodoModel) Assigned(userID string) ([]*Todo, error) {
+	stmt := `SELECT ` + todoColumns + ` FROM todos
+	WHERE ` + readableTodo + ` AND assignee_id = ? AND status = false AND deleted_at IS NULL
+	ORDER BY due_at IS NULL, due_at, priority DESC, created`
+
+	rows, err := m.DB.Query(stmt, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []*Todo{}
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = m.loadTags(todos)
+	if err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// unassignMember removes the assignments of memberID in a list they are
+// leaving, recording the change as made by userID
+func unassignMember(tx *sql.Tx, userID, listID, memberID string) error {
+	rows, err := tx.Query(`SELECT id FROM todos WHERE list_id = ? AND assignee_id = ?`, listID, memberID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		err = rows.Scan(&id)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rr, err := trackRevisions(tx, userID, ActionAssign, ids...)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE todos SET assignee_id = NULL WHERE list_id = ? AND assignee_id = ?`, listID, memberID)
+	if err != nil {
+		return err
+	}
+
+	return rr.record()
+}
+
+// unassignNonMembers removes the assignee of the todos ids when they are not
+// a member of listID, the list the todos were moved to
+func unassignNonMembers(tx *sql.Tx, listID string, ids []string) error {
+	args := []any{}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, listID)
+
+	stmt := `UPDATE todos SET assignee_id = NULL
+	WHERE id IN (` + placeholders(len(ids)) + `) AND assignee_id IS NOT NULL
+	AND assignee_id NOT IN (SELECT user_id FROM list_members WHERE list_id = ?)`
+
+	_, err := tx.Exec(stmt, args...)
+	return err
+}
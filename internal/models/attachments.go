@@ -0,0 +1,247 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// define an attachment type, attachments are files uploaded to a todo. Only
+// their metadata is kept in the database, the content is kept in a
+// storage.Store under its checksum.
+type Attachment struct {
+	ID          string
+	TodoID      string
+	Filename    string
+	ContentType string
+	Size        int64
+	Checksum    string
+	Created     time.Time
+}
+
+// define an attachment model type which wraps a sql.DB connection pool
+type AttachmentModel struct {
+	DB *sql.DB
+}
+
+// attachmentColumns lists the columns read by scanAttachment, in scan order
+const attachmentColumns = `a.id, a.todo_id, a.filename, a.content_type, a.size, a.checksum, a.created`
+
+// scanAttachment copies a row selected with attachmentColumns into a new Attachment
+func scanAttachment(row scanner) (*Attachment, error) {
+	a := &Attachment{}
+	err := row.Scan(&a.ID, &a.TodoID, &a.Filename, &a.ContentType, &a.Size, &a.Checksum, &a.Created)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// insert a new attachment into the database, a.ID and a.TodoID must already
+// be set. ErrNoRecord is returned unless the todo is a live todo of a list
+// userID is an editor of. store is called to put the content in the store
+// before the attachment is committed, with the content locked so that
+// Release cannot remove it meanwhile.
+func (m *AttachmentModel) Insert(userID string, a *Attachment, store func() error) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	err = lockContent(tx, a.Checksum)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO attachments (id, todo_id, filename, content_type, size, checksum, created)
+	SELECT ?, id, ?, ?, ?, ?, UTC_TIMESTAMP() FROM todos
+	WHERE id = ? AND ` + writableTodo + ` AND deleted_at IS NULL`
+
+	result, err := tx.Exec(stmt, a.ID, a.Filename, a.ContentType, a.Size, a.Checksum, a.TodoID, userID)
+	if err != nil {
+		log.Printf("Error while inserting an attachment: %s", err)
+		return err
+	}
+	err = checkRowsAffected(result)
+	if err != nil {
+		return err
+	}
+
+	err = store()
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// return an attachment of a live todo of a list userID is a member of
+func (m *AttachmentModel) Get(userID, todoID, id string) (*Attachment, error) {
+	stmt := `SELECT ` + attachmentColumns + ` FROM attachments a
+	JOIN todos t ON t.id = a.todo_id
+	WHERE a.id = ? AND a.todo_id = ? AND ` + memberOf("t.list_id", RoleViewer) + ` AND t.deleted_at IS NULL`
+
+	a, err := scanAttachment(m.DB.QueryRow(stmt, id, todoID, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// return the attachments of a live todo of a list userID is a member of,
+// oldest first
+func (m *AttachmentModel) All(userID, todoID string) ([]*Attachment, error) {
+	var exists bool
+	stmt := `SELECT EXISTS(SELECT true FROM todos WHERE id = ? AND ` + readableTodo + ` AND deleted_at IS NULL)`
+	err := m.DB.QueryRow(stmt, todoID, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNoRecord
+	}
+
+	stmt = `SELECT ` + attachmentColumns + ` FROM attachments a
+	WHERE a.todo_id = ?
+	ORDER BY a.created, a.id`
+
+	rows, err := m.DB.Query(stmt, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []*Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// delete an attachment of a live todo of a list userID is an editor of. The
+// content is left in the store, see Release.
+func (m *AttachmentModel) Delete(userID, todoID, id string) error {
+	stmt := `DELETE a FROM attachments a
+	JOIN todos t ON t.id = a.todo_id
+	WHERE a.id = ? AND a.todo_id = ? AND ` + memberOf("t.list_id", RoleEditor) + ` AND t.deleted_at IS NULL`
+
+	result, err := m.DB.Exec(stmt, id, todoID, userID)
+	if err != nil {
+		log.Printf("Error while deleting an attachment: %s", err)
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// call remove to delete the content with the given checksum from the store
+// if no attachment uses it anymore, contents being shared by identical
+// files. The content stays locked until remove returns, so that no
+// attachment can start using it meanwhile.
+func (m *AttachmentModel) Release(checksum string, remove func() error) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockContent(tx, checksum)
+	if err != nil {
+		return err
+	}
+
+	var referenced bool
+	stmt := `SELECT EXISTS(SELECT true FROM attachments WHERE checksum = ?)`
+	err = tx.QueryRow(stmt, checksum).Scan(&referenced)
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return tx.Commit()
+	}
+
+	err = remove()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM attachment_contents WHERE checksum = ?`, checksum)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockContent locks the row of a content in attachment_contents until the
+// end of tx, creating the row if needed
+func lockContent(tx *sql.Tx, checksum string) error {
+	stmt := `INSERT INTO attachment_contents (checksum) VALUES(?)
+	ON DUPLICATE KEY UPDATE checksum = checksum`
+
+	_, err := tx.Exec(stmt, checksum)
+	return err
+}
+
+// return the checksums of the attachments of a todo of a list userID is an
+// editor of and of its subtasks at any depth, which go with the todo when it
+// is purged
+func (m *AttachmentModel) TreeChecksums(userID, todoID string) ([]string, error) {
+	stmt := `WITH RECURSIVE subtree (id) AS (
+		SELECT id FROM todos WHERE id = ? AND ` + writableTodo + `
+		UNION ALL
+		SELECT c.id FROM todos c JOIN subtree s ON c.parent_id = s.id
+	)
+	SELECT DISTINCT a.checksum FROM attachments a JOIN subtree s ON s.id = a.todo_id`
+
+	return m.checksums(stmt, todoID, userID)
+}
+
+// return the checksums of the attachments of the todos trashed before t,
+// see TodoModel.PurgeDeletedBefore. Subtasks are always trashed no later
+// than their parent, so they are included.
+func (m *AttachmentModel) ChecksumsDeletedBefore(t time.Time) ([]string, error) {
+	stmt := `SELECT DISTINCT a.checksum FROM attachments a
+	JOIN todos t ON t.id = a.todo_id
+	WHERE t.deleted_at < ?`
+
+	return m.checksums(stmt, t.UTC())
+}
+
+func (m *AttachmentModel) checksums(stmt string, args ...any) ([]string, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checksums := []string{}
+	for rows.Next() {
+		var checksum string
+		err = rows.Scan(&checksum)
+		if err != nil {
+			return nil, err
+		}
+		checksums = append(checksums, checksum)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return checksums, nil
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// define a comment type, comments form the discussion thread of a todo.
+// Only their author can edit or delete them.
+type Comment struct {
+	ID     string
+	TodoID string
+	// ID and name of the author
+	UserID     string
+	AuthorName string
+	Body       string
+	Created    time.Time
+	// time of the last edit, nil for comments never edited
+	EditedAt *time.Time
+}
+
+// define a comment model type which wraps a sql.DB connection pool
+type CommentModel struct {
+	DB *sql.DB
+}
+
+// commentColumns lists the columns read by scanComment, in scan order
+const commentColumns = `cm.id, cm.todo_id, cm.user_id, COALESCE(u.name, ''), cm.body, cm.created, cm.edited_at`
+
+// scanComment copies a row selected with commentColumns into a new Comment
+func scanComment(row scanner) (*Comment, error) {
+	c := &Comment{}
+	var editedAt sql.NullTime
+	err := row.Scan(&c.ID, &c.TodoID, &c.UserID, &c.AuthorName, &c.Body, &c.Created, &editedAt)
+	if err != nil {
+		return nil, err
+	}
+	c.EditedAt = nullTimePtr(editedAt)
+	return c, nil
+}
+
+// insert a new comment of userID on a live todo of a list they are an
+// editor of, c.ID and c.TodoID must already be set. ErrNoRecord is returned
+// if there is no such todo.
+func (m *CommentModel) Insert(userID string, c *Comment) error {
+	stmt := `INSERT INTO comments (id, todo_id, user_id, body, created)
+	SELECT ?, id, ?, ?, UTC_TIMESTAMP(6) FROM todos
+	WHERE id = ? AND ` + writableTodo + ` AND deleted_at IS NULL`
+
+	result, err := m.DB.Exec(stmt, c.ID, userID, c.Body, c.TodoID, userID)
+	if err != nil {
+		log.Printf("Error while inserting a comment: %s", err)
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// return a comment on a live todo of a list userID is a member of
+func (m *CommentModel) Get(userID, todoID, id string) (*Comment, error) {
+	stmt := `SELECT ` + commentColumns + ` FROM comments cm
+	JOIN todos t ON t.id = cm.todo_id
+	LEFT JOIN users u ON u.uuid = cm.user_id
+	WHERE cm.id = ? AND cm.todo_id = ? AND ` + memberOf("t.list_id", RoleViewer) + ` AND t.deleted_at IS NULL`
+
+	c, err := scanComment(m.DB.QueryRow(stmt, id, todoID, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// return the comments on a live todo of a list userID is a member of,
+// oldest first
+func (m *CommentModel) All(userID, todoID string) ([]*Comment, error) {
+	var exists bool
+	stmt := `SELECT EXISTS(SELECT true FROM todos WHERE id = ? AND ` + readableTodo + ` AND deleted_at IS NULL)`
+	err := m.DB.QueryRow(stmt, todoID, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNoRecord
+	}
+
+	stmt = `SELECT ` + commentColumns + ` FROM comments cm
+	LEFT JOIN users u ON u.uuid = cm.user_id
+	WHERE cm.todo_id = ?
+	ORDER BY cm.created, cm.id`
+
+	rows, err := m.DB.Query(stmt, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// change the body of a comment written by userID on a live todo of a list
+// they are an editor of. The comment is marked as edited if the body
+// actually changed.
+func (m *CommentModel) Update(userID, todoID, id, body string) error {
+	// edited_at is assigned first, so that it compares the previous body
+	stmt := `UPDATE comments SET edited_at = IF(body = ?, edited_at, UTC_TIMESTAMP(6)), body = ?
+	WHERE id = ? AND todo_id = ? AND user_id = ?
+	AND todo_id IN (SELECT id FROM todos WHERE ` + writableTodo + ` AND deleted_at IS NULL)`
+
+	result, err := m.DB.Exec(stmt, body, body, id, todoID, userID, userID)
+	if err != nil {
+		log.Printf("Error while updating a comment: %s", err)
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// delete a comment written by userID on a live todo of a list they are an
+// editor of
+func (m *CommentModel) Delete(userID, todoID, id string) error {
+	stmt := `DELETE FROM comments
+	WHERE id = ? AND todo_id = ? AND user_id = ?
+	AND todo_id IN (SELECT id FROM todos WHERE ` + writableTodo + ` AND deleted_at IS NULL)`
+
+	result, err := m.DB.Exec(stmt, id, todoID, userID, userID)
+	if err != nil {
+		log.Printf("Error while deleting a comment: %s", err)
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
@@ -0,0 +1,321 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"sort"
+)
+
+// make the todo id wait for blockerID: it cannot be completed while
+// blockerID is open. userID must be an editor of the list of id and a member
+// of the list of blockerID. ErrCycle is returned if blockerID already waits
+// for id, directly or through other todos.
+func (m *TodoModel) Block(userID, id, blockerID string) error {
+	if id == blockerID {
+		return ErrCycle
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	// both todos are locked, see lockUpstream
+	stmt := `SELECT id FROM todos
+	WHERE ((id = ? AND ` + writableTodo + `) OR (id = ? AND ` + readableTodo + `)) AND deleted_at IS NULL
+	FOR UPDATE`
+	rows, err := tx.Query(stmt, id, userID, blockerID, userID)
+	if err != nil {
+		return err
+	}
+	found := 0
+	for rows.Next() {
+		found++
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if found != 2 {
+		return ErrNoRecord
+	}
+
+	err = lockUpstream(tx, id, blockerID)
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO todo_dependencies (todo_id, blocker_id, created) VALUES (?, ?, UTC_TIMESTAMP())
+	ON DUPLICATE KEY UPDATE created = created`
+	_, err = tx.Exec(stmt, id, blockerID)
+	if err != nil {
+		log.Printf("Error while adding a dependency %s", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockUpstream locks blockerID and the todos it waits for, at any depth,
+// until the end of tx, and returns ErrCycle if id is one of them. The
+// dependencies are read past the snapshot of tx as each level is locked.
+// Two dependencies closing a cycle together have to lock a common todo, so
+// the second one waits for the first and then finds the cycle.
+func lockUpstream(tx *sql.Tx, id, blockerID string) error {
+	seen := map[string]bool{blockerID: true}
+	level := []any{blockerID}
+	for len(level) > 0 {
+		stmt := `SELECT id FROM todos WHERE id IN (` + placeholders(len(level)) + `) FOR UPDATE`
+		rows, err := tx.Query(stmt, level...)
+		if err != nil {
+			return err
+		}
+		rows.Close()
+
+		stmt = `SELECT blocker_id FROM todo_dependencies WHERE todo_id IN (` + placeholders(len(level)) + `) FOR SHARE`
+		rows, err = tx.Query(stmt, level...)
+		if err != nil {
+			return err
+		}
+		level = []any{}
+		for rows.Next() {
+			var upstreamID string
+			err = rows.Scan(&upstreamID)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			if upstreamID == id {
+				rows.Close()
+				return ErrCycle
+			}
+			if !seen[upstreamID] {
+				seen[upstreamID] = true
+				level = append(level, upstreamID)
+			}
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stop the todo id from waiting for blockerID, userID must be an editor of
+// the list of id
+func (m *TodoModel) Unblock(userID, id, blockerID string) error {
+	stmt := `DELETE FROM todo_dependencies WHERE todo_id = ? AND blocker_id = ?
+	AND todo_id IN (SELECT id FROM todos WHERE ` + writableTodo + ` AND deleted_at IS NULL)`
+
+	result, err := m.DB.Exec(stmt, id, blockerID, userID)
+	if err != nil {
+		log.Printf("Error while removing a dependency %s", err)
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// return the todos a live todo of a list userID is a member of waits for,
+// and those waiting for it. Only the live todos userID can see are
+// returned, open ones first.
+func (m *TodoModel) Dependencies(userID, id string) (blockers, blocking []*Todo, err error) {
+	var exists bool
+	stmt := `SELECT EXISTS(SELECT true FROM todos WHERE id = ? AND ` + readableTodo + ` AND deleted_at IS NULL)`
+	err = m.DB.QueryRow(stmt, id, userID).Scan(&exists)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exists {
+		return nil, nil, ErrNoRecord
+	}
+
+	blockers, err = m.dependencyTodos(userID, `SELECT blocker_id FROM todo_dependencies WHERE todo_id = ?`, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	blocking, err = m.dependencyTodos(userID, `SELECT todo_id FROM todo_dependencies WHERE blocker_id = ?`, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return blockers, blocking, nil
+}
+
+// dependencyTodos returns the live todos userID can see among the IDs
+// selected by subquery
+func (m *TodoModel) dependencyTodos(userID, subquery, id string) ([]*Todo, error) {
+	stmt := `SELECT ` + todoColumns + ` FROM todos
+	WHERE id IN (` + subquery + `) AND ` + readableTodo + ` AND deleted_at IS NULL
+	ORDER BY status, created, id`
+
+	rows, err := m.DB.Query(stmt, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []*Todo{}
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = m.loadTags(todos)
+	if err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// checkBlockers returns ErrBlocked if one of the todos ids waits for an
+// open, live todo. Blockers among ids do not count, as they are completed
+// together. A blocker blocks whoever completes the todo, even a user who
+// cannot see it.
+func checkBlockers(q querier, ids []string) error {
+	args := make([]any, 0, 2*len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	var blocked bool
+	stmt := `SELECT EXISTS(SELECT true FROM todo_dependencies d
+	JOIN todos ON todos.id = d.blocker_id
+	WHERE d.todo_id IN (` + placeholders(len(ids)) + `) AND d.blocker_id NOT IN (` + placeholders(len(ids)) + `)
+	AND todos.status = false AND todos.deleted_at IS NULL)`
+	err := q.QueryRow(stmt, args...).Scan(&blocked)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+	return nil
+}
+
+// return the open, live todos of the lists userID is a member of, or of one
+// list if listID is not empty, in the order they can be done. ready holds
+// the todos which wait for no open todo, the most urgent first. waiting
+// holds the others, each after the todos it waits for. Todos waiting for an
+// open todo outside of the list come last.
+func (m *TodoModel) Next(userID, listID string) (ready, waiting []*Todo, err error) {
+	stmt := `SELECT ` + todoColumns + ` FROM todos
+	WHERE status = false AND ` + readableTodo + ` AND deleted_at IS NULL`
+	args := []any{userID}
+	if listID != "" {
+		stmt += ` AND list_id = ?`
+		args = append(args, listID)
+	}
+	stmt += ` ORDER BY priority DESC, important DESC, due_at IS NULL, due_at, position, id`
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	todos := []*Todo{}
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		todos = append(todos, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	err = m.loadTags(todos)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the open blockers of the open todos, whatever their list
+	stmt = `SELECT d.todo_id, d.blocker_id FROM todo_dependencies d
+	JOIN todos ON todos.id = d.blocker_id
+	WHERE todos.status = false AND ` + readableTodo + ` AND todos.deleted_at IS NULL`
+
+	rows, err = m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	rank := make(map[string]int, len(todos))
+	for i, t := range todos {
+		rank[t.ID] = i
+	}
+	// the number of open blockers of each todo, and the todos waiting for
+	// each blocker
+	pending := make([]int, len(todos))
+	dependents := make(map[string][]int)
+	for rows.Next() {
+		var id, blockerID string
+		err = rows.Scan(&id, &blockerID)
+		if err != nil {
+			return nil, nil, err
+		}
+		i, ok := rank[id]
+		if !ok {
+			continue
+		}
+		pending[i]++
+		dependents[blockerID] = append(dependents[blockerID], i)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// topological sort by levels: each level holds the todos whose blockers
+	// are all in the previous levels, in the order they were read
+	level := []int{}
+	for i := range todos {
+		if pending[i] == 0 {
+			level = append(level, i)
+		}
+	}
+	ready = []*Todo{}
+	waiting = []*Todo{}
+	done := make([]bool, len(todos))
+	for first := true; len(level) > 0; first = false {
+		next := []int{}
+		for _, i := range level {
+			done[i] = true
+			if first {
+				ready = append(ready, todos[i])
+			} else {
+				waiting = append(waiting, todos[i])
+			}
+			for _, j := range dependents[todos[i].ID] {
+				pending[j]--
+				if pending[j] == 0 {
+					next = append(next, j)
+				}
+			}
+		}
+		sort.Ints(next)
+		level = next
+	}
+	for i, t := range todos {
+		if !done[i] {
+			waiting = append(waiting, t)
+		}
+	}
+
+	return ready, waiting, nil
+}
@@ -18,4 +18,68 @@ var (
 	// ErrDuplicateEmail error will be used if a user tries to
 	// signup with an email address that's already in use
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	// ErrDuplicateName error will be used if a user tries to create
+	// a second tag or list with the same name
+	ErrDuplicateName = errors.New("models: duplicate name")
+
+	// ErrInbox error will be used if a user tries to delete or archive
+	// their inbox
+	ErrInbox = errors.New("models: the inbox cannot be deleted or archived")
+
+	// ErrCycle error will be used if a change would make a todo
+	// depend on itself, e.g. by nesting it under one of its subtasks
+	ErrCycle = errors.New("models: cycle detected")
+
+	// ErrInvalidPosition error will be used if a todo is moved next to
+	// todos which do not exist, or which are not neighbours in that order
+	ErrInvalidPosition = errors.New("models: invalid position")
+
+	// ErrInvalidCursor error will be used if a pagination cursor cannot be
+	// decoded, or was issued for a different sort order
+	ErrInvalidCursor = errors.New("models: invalid cursor")
+
+	// ErrForbidden error will be used if a user tries to change a record
+	// their role does not allow them to change
+	ErrForbidden = errors.New("models: forbidden")
+
+	// ErrDuplicateMember error will be used if a list is shared with
+	// one of its members again
+	ErrDuplicateMember = errors.New("models: duplicate member")
+
+	// ErrListOwner error will be used if the creator of a list would
+	// lose the owner role or leave the list
+	ErrListOwner = errors.New("models: the creator of a list must stay its owner")
+
+	// ErrNotMember error will be used if a todo is assigned to a user
+	// who is not a member of its list
+	ErrNotMember = errors.New("models: not a member of the list")
+
+	// ErrUnknownState error will be used if a todo is moved to a state
+	// which is not part of the workflow of its list
+	ErrUnknownState = errors.New("models: unknown state")
+
+	// ErrIllegalTransition error will be used if a todo is moved to a
+	// state its current state does not lead to
+	ErrIllegalTransition = errors.New("models: illegal transition")
+
+	// ErrFixedState error will be used if a user tries to delete the
+	// initial or final state of their workflow
+	ErrFixedState = errors.New("models: the initial and final states cannot be deleted")
+
+	// ErrStateInUse error will be used if a user tries to delete a state
+	// some todos are in
+	ErrStateInUse = errors.New("models: state in use")
+
+	// ErrTimerRunning error will be used if a user tries to start a
+	// timer while another one of theirs is running
+	ErrTimerRunning = errors.New("models: a timer is already running")
+
+	// ErrNoTimer error will be used if a user tries to stop a timer
+	// which is not running
+	ErrNoTimer = errors.New("models: no running timer")
+
+	// ErrBlocked error will be used if a user tries to complete a todo
+	// which waits for open todos
+	ErrBlocked = errors.New("models: blocked by open todos")
 )
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"time"
+)
+
+// EstimateAccuracy compares the estimates a user gave in one unit with the
+// actual duration of the todos, from their creation to their completion
+type EstimateAccuracy struct {
+	// ID and name of the user who gave the estimates
+	UserID   string
+	UserName string
+	Unit     string
+	// number of estimated todos completed, the sum of their estimates and
+	// of their actual durations
+	Todos     int
+	Estimated int
+	Actual    time.Duration
+	// actual over estimated duration, above 1 when the todos took longer
+	// than estimated. Points have no duration of their own, so they are
+	// given the average duration of a point over the whole range: only
+	// the ratios of the weeks tell something, and Ratio is nil.
+	Ratio *float64
+	// average actual duration of a point, nil for minutes
+	PointDuration *time.Duration
+	// the same figures for each week with completions, in order
+	Weeks []EstimateWeek
+}
+
+// EstimateWeek compares the estimates with the actual durations of the todos
+// completed in a week
+type EstimateWeek struct {
+	// the Monday starting the week, as a date at midnight in the time zone
+	// of the range
+	Start     time.Time
+	Todos     int
+	Estimated int
+	Actual    time.Duration
+	Ratio     float64
+}
+
+// return the accuracy of the estimates of each user who estimated the live
+// todos of the lists userID is a member of, or of one list if listID is not
+// empty, which were completed from the day of from to the day of to
+// included. Days and weeks are those of the time zone of from and to.
+func (m *TodoModel) Estimates(userID, listID string, from, to time.Time) ([]*EstimateAccuracy, error) {
+	stmt := `SELECT todos.estimated_by, COALESCE(u.name, ''), todos.estimate_unit, todos.estimate,
+	GREATEST(TIMESTAMPDIFF(SECOND, todos.created, todos.completed_at), 0), todos.completed_at
+	FROM todos
+	LEFT JOIN users u ON u.uuid = todos.estimated_by
+	WHERE todos.estimate IS NOT NULL AND todos.estimated_by IS NOT NULL AND todos.status = true AND todos.completed_at >= ? AND todos.completed_at < ?
+	AND ` + readableTodo + ` AND todos.deleted_at IS NULL`
+	args := []any{from.UTC(), to.AddDate(0, 0, 1).UTC(), userID}
+	if listID != "" {
+		stmt += ` AND todos.list_id = ?`
+		args = append(args, listID)
+	}
+	stmt += ` ORDER BY u.name, todos.estimated_by, todos.estimate_unit, todos.completed_at`
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	estimates := []estimateRow{}
+	for rows.Next() {
+		var e estimateRow
+		var seconds int64
+		err = rows.Scan(&e.estimatedBy, &e.name, &e.unit, &e.estimate, &seconds, &e.completedAt)
+		if err != nil {
+			return nil, err
+		}
+		e.actual = time.Duration(seconds) * time.Second
+		estimates = append(estimates, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return estimateAccuracies(estimates, from.Location()), nil
+}
+
+// estimateRow is a completed todo with an estimate, as read by Estimates
+type estimateRow struct {
+	estimatedBy string
+	name        string
+	unit        string
+	estimate    int
+	actual      time.Duration
+	completedAt time.Time
+}
+
+// estimateAccuracies groups completed todos by estimator and unit, and their
+// completions by week in loc. The todos come sorted by estimator, unit and
+// completion time.
+func estimateAccuracies(estimates []estimateRow, loc *time.Location) []*EstimateAccuracy {
+	accuracies := []*EstimateAccuracy{}
+	var a *EstimateAccuracy
+	for _, e := range estimates {
+		if a == nil || a.UserID != e.estimatedBy || a.Unit != e.unit {
+			a = &EstimateAccuracy{UserID: e.estimatedBy, UserName: e.name, Unit: e.unit, Weeks: []EstimateWeek{}}
+			accuracies = append(accuracies, a)
+		}
+		a.Todos++
+		a.Estimated += e.estimate
+		a.Actual += e.actual
+
+		// rows come in order of completion, so weeks do too
+		day := e.completedAt.In(loc)
+		day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+		monday := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		if len(a.Weeks) == 0 || !a.Weeks[len(a.Weeks)-1].Start.Equal(monday) {
+			a.Weeks = append(a.Weeks, EstimateWeek{Start: monday})
+		}
+		w := &a.Weeks[len(a.Weeks)-1]
+		w.Todos++
+		w.Estimated += e.estimate
+		w.Actual += e.actual
+	}
+
+	for _, a := range accuracies {
+		// the estimated duration of one unit
+		unit := time.Minute
+		if a.Unit == EstimatePoints {
+			unit = a.Actual / time.Duration(a.Estimated)
+			a.PointDuration = &unit
+		} else {
+			ratio := estimateRatio(a.Actual, a.Estimated, unit)
+			a.Ratio = &ratio
+		}
+		for i := range a.Weeks {
+			a.Weeks[i].Ratio = estimateRatio(a.Weeks[i].Actual, a.Weeks[i].Estimated, unit)
+		}
+	}
+
+	return accuracies
+}
+
+// estimateRatio returns actual over the estimated duration of estimate
+// units, 0 if the estimated duration is none
+func estimateRatio(actual time.Duration, estimate int, unit time.Duration) float64 {
+	estimated := time.Duration(estimate) * unit
+	if estimated <= 0 {
+		return 0
+	}
+	return float64(actual) / float64(estimated)
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEstimateAccuracies(t *testing.T) {
+	// a day starts four hours after midnight UTC
+	loc := time.FixedZone("UTC-4", -4*60*60)
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, loc).UTC()
+	}
+	monday := func(month time.Month, day int) time.Time {
+		return time.Date(2024, month, day, 0, 0, 0, 0, loc)
+	}
+
+	estimates := []estimateRow{
+		// Sunday 12 May at 23:00 is already Monday in UTC, but still in
+		// the week of 6 May
+		{estimatedBy: "u1", name: "Ann", unit: EstimateMinutes, estimate: 30, actual: 45 * time.Minute, completedAt: at(time.May, 6, 9)},
+		{estimatedBy: "u1", name: "Ann", unit: EstimateMinutes, estimate: 60, actual: 30 * time.Minute, completedAt: at(time.May, 12, 23)},
+		{estimatedBy: "u1", name: "Ann", unit: EstimateMinutes, estimate: 10, actual: 20 * time.Minute, completedAt: at(time.May, 13, 8)},
+		{estimatedBy: "u1", name: "Ann", unit: EstimatePoints, estimate: 2, actual: 4 * time.Hour, completedAt: at(time.May, 7, 10)},
+		{estimatedBy: "u1", name: "Ann", unit: EstimatePoints, estimate: 3, actual: 1 * time.Hour, completedAt: at(time.May, 14, 10)},
+		{estimatedBy: "u2", name: "Bob", unit: EstimateMinutes, estimate: 40, actual: 20 * time.Minute, completedAt: at(time.May, 8, 12)},
+	}
+
+	tests := []struct {
+		name          string
+		userID        string
+		unit          string
+		todos         int
+		estimated     int
+		actual        time.Duration
+		ratio         *float64
+		pointDuration *time.Duration
+		weeks         []EstimateWeek
+	}{
+		{
+			name:      "Minutes",
+			userID:    "u1",
+			unit:      EstimateMinutes,
+			todos:     3,
+			estimated: 100,
+			actual:    95 * time.Minute,
+			ratio:     ptr(0.95),
+			weeks: []EstimateWeek{
+				{Start: monday(time.May, 6), Todos: 2, Estimated: 90, Actual: 75 * time.Minute, Ratio: 75.0 / 90},
+				{Start: monday(time.May, 13), Todos: 1, Estimated: 10, Actual: 20 * time.Minute, Ratio: 2},
+			},
+		},
+		{
+			name:          "Points",
+			userID:        "u1",
+			unit:          EstimatePoints,
+			todos:         2,
+			estimated:     5,
+			actual:        5 * time.Hour,
+			pointDuration: ptr(time.Hour),
+			weeks: []EstimateWeek{
+				{Start: monday(time.May, 6), Todos: 1, Estimated: 2, Actual: 4 * time.Hour, Ratio: 2},
+				{Start: monday(time.May, 13), Todos: 1, Estimated: 3, Actual: 1 * time.Hour, Ratio: 1.0 / 3},
+			},
+		},
+		{
+			name:      "Other user",
+			userID:    "u2",
+			unit:      EstimateMinutes,
+			todos:     1,
+			estimated: 40,
+			actual:    20 * time.Minute,
+			ratio:     ptr(0.5),
+			weeks: []EstimateWeek{
+				{Start: monday(time.May, 6), Todos: 1, Estimated: 40, Actual: 20 * time.Minute, Ratio: 0.5},
+			},
+		},
+	}
+
+	accuracies := estimateAccuracies(estimates, loc)
+	if len(accuracies) != len(tests) {
+		t.Fatalf("got %d groups; want %d", len(accuracies), len(tests))
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := accuracies[i]
+			if a.UserID != tt.userID || a.Unit != tt.unit {
+				t.Fatalf("got the group of %s in %s; want %s in %s", a.UserID, a.Unit, tt.userID, tt.unit)
+			}
+			if a.Todos != tt.todos || a.Estimated != tt.estimated || a.Actual != tt.actual {
+				t.Errorf("got %d todos, %d estimated, %s actual; want %d, %d, %s",
+					a.Todos, a.Estimated, a.Actual, tt.todos, tt.estimated, tt.actual)
+			}
+			if (a.Ratio == nil) != (tt.ratio == nil) || a.Ratio != nil && !almostEqual(*a.Ratio, *tt.ratio) {
+				t.Errorf("got ratio %v; want %v", deref(a.Ratio), deref(tt.ratio))
+			}
+			if (a.PointDuration == nil) != (tt.pointDuration == nil) || a.PointDuration != nil && *a.PointDuration != *tt.pointDuration {
+				t.Errorf("got point duration %v; want %v", deref(a.PointDuration), deref(tt.pointDuration))
+			}
+
+			if len(a.Weeks) != len(tt.weeks) {
+				t.Fatalf("got %d weeks; want %d", len(a.Weeks), len(tt.weeks))
+			}
+			for j, want := range tt.weeks {
+				got := a.Weeks[j]
+				if !got.Start.Equal(want.Start) || got.Todos != want.Todos || got.Estimated != want.Estimated ||
+					got.Actual != want.Actual || !almostEqual(got.Ratio, want.Ratio) {
+					t.Errorf("week %d: got %+v; want %+v", j, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEstimateRatio(t *testing.T) {
+	tests := []struct {
+		name     string
+		actual   time.Duration
+		estimate int
+		unit     time.Duration
+		want     float64
+	}{
+		{name: "On time", actual: time.Hour, estimate: 60, unit: time.Minute, want: 1},
+		{name: "Late", actual: 90 * time.Minute, estimate: 60, unit: time.Minute, want: 1.5},
+		{name: "Early", actual: 15 * time.Minute, estimate: 60, unit: time.Minute, want: 0.25},
+		{name: "No estimate", actual: time.Hour, estimate: 0, unit: time.Minute, want: 0},
+		{name: "Points worth nothing", actual: time.Hour, estimate: 3, unit: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := estimateRatio(tt.actual, tt.estimate, tt.unit); !almostEqual(got, tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func deref[T any](p *T) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
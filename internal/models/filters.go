@@ -0,0 +1,108 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// TodoSortSafelist holds the sort values accepted by TodoModel.List.
+// A leading "-" sorts in descending order.
+var TodoSortSafelist = []string{"created", "-created", "body", "position"}
+
+// TodoFilter describes which page of a user's todos should be returned
+type TodoFilter struct {
+	// maximum number of todos per page
+	Limit int
+	// opaque cursor returned with the previous page, empty for the first page
+	Cursor string
+	// only return the todos of this list, empty for all lists
+	ListID string
+	// only return the todos assigned to this user, empty for all todos
+	AssigneeID string
+	// only return top-level todos, or the direct subtasks of ParentID
+	TopLevel bool
+	ParentID string
+	// only return open (false) or done (true) todos, nil for both
+	Status *bool
+	// only return todos created strictly after/before these times, zero for no bound
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// only return todos due within [DueFrom, DueTo), zero for no bound
+	DueFrom time.Time
+	DueTo   time.Time
+	// only return todos carrying any of the named tags,
+	// or all of them if TagsMatchAll is set
+	Tags         []string
+	TagsMatchAll bool
+	// also return the todos snoozed until a later time
+	IncludeSnoozed bool
+	// one of TodoSortSafelist
+	Sort string
+}
+
+// sortColumn returns the column to sort by for the filter
+func (f TodoFilter) sortColumn() string {
+	return strings.TrimPrefix(f.Sort, "-")
+}
+
+// sortDescending reports whether the filter sorts in descending order
+func (f TodoFilter) sortDescending() bool {
+	return strings.HasPrefix(f.Sort, "-")
+}
+
+// cursor is the decoded form of the opaque pagination cursor. It holds the
+// sort key and ID of the last todo of a page, so that the next page can
+// continue right after it without using OFFSET.
+type cursor struct {
+	Sort     string    `json:"s"`
+	ID       string    `json:"id"`
+	Created  time.Time `json:"c,omitempty"`
+	Body     string    `json:"b,omitempty"`
+	Position string    `json:"p,omitempty"`
+}
+
+// encodeCursor returns the cursor pointing after t for the given sort order
+func encodeCursor(sort string, t *Todo) string {
+	c := cursor{Sort: sort, ID: t.ID}
+	switch strings.TrimPrefix(sort, "-") {
+	case "created":
+		c.Created = t.Created
+	case "body":
+		c.Body = t.Body
+	case "position":
+		c.Position = t.Position
+	}
+
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// decodeCursor parses a cursor and makes sure it was issued for the given
+// sort order
+func decodeCursor(sort, s string) (*cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	err = json.Unmarshal(js, &c)
+	if err != nil || c.Sort != sort || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// value returns the sort key stored in the cursor
+func (c *cursor) value() any {
+	switch strings.TrimPrefix(c.Sort, "-") {
+	case "body":
+		return c.Body
+	case "position":
+		return c.Position
+	}
+	return c.Created
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	todo := &Todo{
+		ID:       "6f1c7d0e-8a8b-4f5e-9d3c-2b1a0e9f8d7c",
+		Body:     "Buy milk",
+		Position: "a0V",
+		Created:  time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		sort string
+		want any
+	}{
+		{sort: "created", want: todo.Created},
+		{sort: "-created", want: todo.Created},
+		{sort: "body", want: todo.Body},
+		{sort: "position", want: todo.Position},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			c, err := decodeCursor(tt.sort, encodeCursor(tt.sort, todo))
+			if err != nil {
+				t.Fatalf("decodeCursor: %s", err)
+			}
+			if c.ID != todo.ID {
+				t.Errorf("got ID %q; want %q", c.ID, todo.ID)
+			}
+			if got := c.value(); got != tt.want {
+				t.Errorf("got value %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	todo := &Todo{ID: "6f1c7d0e-8a8b-4f5e-9d3c-2b1a0e9f8d7c", Body: "Buy milk"}
+	valid := encodeCursor("body", todo)
+
+	// flip one character in the middle of a valid cursor
+	tampered := []byte(valid)
+	if tampered[len(tampered)/2] == 'A' {
+		tampered[len(tampered)/2] = 'B'
+	} else {
+		tampered[len(tampered)/2] = 'A'
+	}
+
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+	}{
+		{name: "Empty", sort: "body", cursor: ""},
+		{name: "Garbage", sort: "body", cursor: "not a cursor!"},
+		{name: "Padded base64", sort: "body", cursor: valid + "=="},
+		{name: "Tampered", sort: "body", cursor: string(tampered)},
+		{name: "Not JSON", sort: "body", cursor: base64.RawURLEncoding.EncodeToString([]byte("body:Buy milk"))},
+		{name: "Missing ID", sort: "body", cursor: base64.RawURLEncoding.EncodeToString([]byte(`{"s":"body","b":"Buy milk"}`))},
+		{name: "Other sort", sort: "created", cursor: valid},
+		{name: "Other direction", sort: "-body", cursor: valid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCursor(tt.sort, tt.cursor)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("got error %v; want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// InboxName is the name given to the default list of every user
+const InboxName = "Inbox"
+
+// define a list type, lists (projects) group the todos of a user and can be
+// shared with other users, see Member. Every user has exactly one inbox,
+// which receives the todos created without a list, stays private and
+// cannot be deleted or archived.
+type List struct {
+	ID string
+	// the creator of the list
+	UserID   string
+	Name     string
+	Color    string
+	Icon     string
+	Archived bool
+	Inbox    bool
+	Created  time.Time
+	// role of the user the list was read for
+	Role string
+}
+
+// define a list model type which wraps a sql.DB connection pool
+type ListModel struct {
+	DB *sql.DB
+}
+
+// listColumns lists the columns read by scanList, in scan order. They are
+// read from the lists table joined with the membership of a user as lm.
+const listColumns = `lists.id, lists.user_id, name, color, icon, archived, inbox IS NOT NULL, lists.created, lm.role`
+
+// scanList copies a row selected with listColumns into a new List
+func scanList(row scanner) (*List, error) {
+	l := &List{}
+	err := row.Scan(&l.ID, &l.UserID, &l.Name, &l.Color, &l.Icon, &l.Archived, &l.Inbox, &l.Created, &l.Role)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// insert a new list into the database, l.ID and l.UserID must already be
+// set. l.UserID becomes the owner of the list.
+func (m *ListModel) Insert(l *List) (string, error) {
+	// the inbox column is NULL for regular lists, so that the unique key
+	// on (user_id, inbox) only allows a single inbox per user
+	var inbox any
+	if l.Inbox {
+		inbox = true
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO lists (id, user_id, name, color, icon, archived, inbox, created)
+	VALUES(?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = tx.Exec(stmt, l.ID, l.UserID, l.Name, l.Color, l.Icon, l.Archived, inbox)
+	if err != nil {
+		return "", duplicateListError(err)
+	}
+
+	stmt = `INSERT INTO list_members (list_id, user_id, role, created)
+	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = tx.Exec(stmt, l.ID, l.UserID, RoleOwner)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+
+	return l.ID, nil
+}
+
+// return a specific list based on its id, as long as userID is a member
+func (m *ListModel) Get(userID, id string) (*List, error) {
+	stmt := `SELECT ` + listColumns + ` FROM lists
+	JOIN list_members lm ON lm.list_id = lists.id
+	WHERE lists.id = ? AND lm.user_id = ?`
+
+	l, err := scanList(m.DB.QueryRow(stmt, id, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// return the inbox of userID, creating it if the user does not have one yet
+func (m *ListModel) Inbox(userID, newId string) (*List, error) {
+	stmt := `SELECT ` + listColumns + ` FROM lists
+	JOIN list_members lm ON lm.list_id = lists.id AND lm.user_id = lists.user_id
+	WHERE lists.user_id = ? AND inbox IS NOT NULL`
+
+	l, err := scanList(m.DB.QueryRow(stmt, userID))
+	if err == nil {
+		return l, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	l = &List{ID: newId, UserID: userID, Name: InboxName, Inbox: true}
+	_, err = m.Insert(l)
+	if err != nil {
+		// another request created the inbox in the meantime
+		if errors.Is(err, ErrDuplicateName) {
+			return scanList(m.DB.QueryRow(stmt, userID))
+		}
+		return nil, err
+	}
+
+	return m.Get(userID, l.ID)
+}
+
+// return the lists userID is a member of, the inbox first and then by
+// name. Archived lists are only returned if includeArchived is set.
+func (m *ListModel) All(userID string, includeArchived bool) ([]*List, error) {
+	stmt := `SELECT ` + listColumns + ` FROM lists
+	JOIN list_members lm ON lm.list_id = lists.id
+	WHERE lm.user_id = ? AND (archived = false OR ?)
+	ORDER BY inbox IS NULL, name, lists.id`
+
+	rows, err := m.DB.Query(stmt, userID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*List{}
+	for rows.Next() {
+		l, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, l)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+// update the name, color, icon and archived state of a list owned by userID
+func (m *ListModel) Update(userID string, l *List) error {
+	current, err := m.Get(userID, l.ID)
+	if err != nil {
+		return err
+	}
+	if current.Role != RoleOwner {
+		return ErrForbidden
+	}
+	if current.Inbox && l.Archived {
+		return ErrInbox
+	}
+
+	stmt := `UPDATE lists SET name = ?, color = ?, icon = ?, archived = ?
+	WHERE id = ? AND ` + memberOf("lists.id", RoleOwner)
+
+	result, err := m.DB.Exec(stmt, l.Name, l.Color, l.Icon, l.Archived, l.ID, userID)
+	if err != nil {
+		return duplicateListError(err)
+	}
+
+	return checkRowsAffected(result)
+}
+
+// delete a list owned by userID, its todos are moved to the inbox of the
+// creator of the list
+func (m *ListModel) Delete(userID, id string) error {
+	l, err := m.Get(userID, id)
+	if err != nil {
+		return err
+	}
+	if l.Role != RoleOwner {
+		return ErrForbidden
+	}
+	if l.Inbox {
+		return ErrInbox
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	// the inbox is private, so the timers of the other members stop and
+	// only the todos assigned to its owner stay assigned
+	stmt := `UPDATE time_entries SET stopped_at = GREATEST(started_at, UTC_TIMESTAMP(6))
+	WHERE user_id != ? AND stopped_at IS NULL
+	AND todo_id IN (SELECT id FROM todos WHERE list_id = ?)`
+
+	_, err = tx.Exec(stmt, l.UserID, id)
+	if err != nil {
+		return err
+	}
+
+	stmt = `UPDATE todos SET list_id =
+	(SELECT id FROM lists WHERE user_id = ? AND inbox IS NOT NULL),
+	assignee_id = IF(assignee_id = ?, assignee_id, NULL)
+	WHERE list_id = ?`
+
+	_, err = tx.Exec(stmt, l.UserID, l.UserID, id)
+	if err != nil {
+		return err
+	}
+
+	// the memberships go with the list
+	result, err := tx.Exec(`DELETE FROM lists WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	err = checkRowsAffected(result)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// duplicateListError translates a violation of the lists_uc_user_name or
+// lists_uc_user_inbox keys into ErrDuplicateName
+func duplicateListError(err error) error {
+	var mySQLError *mysql.MySQLError
+	if errors.As(err, &mySQLError) {
+		if mySQLError.Number == 1062 &&
+			(strings.Contains(mySQLError.Message, "lists_uc_user_name") ||
+				strings.Contains(mySQLError.Message, "lists_uc_user_inbox")) {
+			return ErrDuplicateName
+		}
+	}
+	return err
+}
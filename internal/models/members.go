@@ -0,0 +1,252 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Roles of the members of a list. Viewers can read its todos, editors can
+// change them as well, and owners can also manage the list and its members.
+// The creator of a list is always one of its owners.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+// Roles holds the roles, from the least to the most rights
+var Roles = []string{RoleViewer, RoleEditor, RoleOwner}
+
+// RoleAtLeast reports whether role grants at least the rights of min
+func RoleAtLeast(role, min string) bool {
+	rank := map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+	return rank[role] > 0 && rank[role] >= rank[min]
+}
+
+// memberOf returns a condition which holds when the list_id expression
+// column is a list whose members include, with at least role, the user
+// bound to the placeholder of the condition
+func memberOf(column, role string) string {
+	roles := []string{}
+	for _, r := range Roles {
+		if RoleAtLeast(r, role) {
+			roles = append(roles, "'"+r+"'")
+		}
+	}
+	return column + ` IN (SELECT lm.list_id FROM list_members lm WHERE lm.user_id = ? AND lm.role IN (` + strings.Join(roles, ", ") + `))`
+}
+
+// conditions selecting the rows of the todos table a user can read or
+// change, through their membership of the list of the todo. Each of them
+// binds the ID of the user once.
+var (
+	readableTodo = memberOf("todos.list_id", RoleViewer)
+	writableTodo = memberOf("todos.list_id", RoleEditor)
+	// the status of a todo can also be changed by its assignee, whatever
+	// their role
+	completableTodo = `todos.list_id IN (SELECT lm.list_id FROM list_members lm WHERE lm.user_id = ?
+	AND (lm.role IN ('editor', 'owner') OR lm.user_id = todos.assignee_id))`
+)
+
+// define a member type, a user sharing a list with a role
+type Member struct {
+	ListID string
+	UserID string
+	Name   string
+	Email  string
+	Role   string
+	// the creator of the list, who cannot leave it or lose the owner role
+	Creator bool
+	Created time.Time
+}
+
+// return the role of userID in a list, ErrNoRecord if they are not a member
+func (m *ListModel) Role(userID, listID string) (string, error) {
+	var role string
+	stmt := `SELECT role FROM list_members WHERE list_id = ? AND user_id = ?`
+	err := m.DB.QueryRow(stmt, listID, userID).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+	return role, nil
+}
+
+// return the role of userID in the list of a todo, trashed or not,
+// ErrNoRecord if they are not a member
+func (m *TodoModel) Role(userID, todoID string) (string, error) {
+	var role string
+	stmt := `SELECT lm.role FROM todos t
+	JOIN list_members lm ON lm.list_id = t.list_id
+	WHERE t.id = ? AND lm.user_id = ?`
+	err := m.DB.QueryRow(stmt, todoID, userID).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+	return role, nil
+}
+
+// return the members of a list userID is a member of, owners first
+func (m *ListModel) Members(userID, listID string) ([]*Member, error) {
+	_, err := m.Role(userID, listID)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `SELECT lm.list_id, lm.user_id, u.name, u.email, lm.role, l.user_id = lm.user_id, lm.created
+	FROM list_members lm
+	JOIN users u ON u.uuid = lm.user_id
+	JOIN lists l ON l.id = lm.list_id
+	WHERE lm.list_id = ?
+	ORDER BY FIELD(lm.role, 'owner', 'editor', 'viewer'), u.name`
+
+	rows, err := m.DB.Query(stmt, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*Member{}
+	for rows.Next() {
+		mb := &Member{}
+		err = rows.Scan(&mb.ListID, &mb.UserID, &mb.Name, &mb.Email, &mb.Role, &mb.Creator, &mb.Created)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, mb)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// share a list owned by userID with memberID. Inboxes stay private.
+func (m *ListModel) AddMember(userID, listID, memberID, role string) error {
+	l, err := m.Get(userID, listID)
+	if err != nil {
+		return err
+	}
+	if l.Role != RoleOwner {
+		return ErrForbidden
+	}
+	if l.Inbox {
+		return ErrInbox
+	}
+
+	stmt := `INSERT INTO list_members (list_id, user_id, role, created)
+	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = m.DB.Exec(stmt, listID, memberID, role)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) && mySQLError.Number == 1062 {
+			return ErrDuplicateMember
+		}
+		log.Printf("Error while sharing a list: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// change the role of a member of a list owned by userID. The creator of
+// the list stays an owner.
+func (m *ListModel) SetMemberRole(userID, listID, memberID, role string) error {
+	current, err := m.Role(userID, listID)
+	if err != nil {
+		return err
+	}
+	if current != RoleOwner {
+		return ErrForbidden
+	}
+
+	stmt := `UPDATE list_members SET role = ?
+	WHERE list_id = ? AND user_id = ?
+	AND list_id IN (SELECT id FROM lists WHERE id = ? AND user_id != ?)`
+
+	result, err := m.DB.Exec(stmt, role, listID, memberID, listID, memberID)
+	if err != nil {
+		log.Printf("Error while changing the role of a member: %s", err)
+		return err
+	}
+
+	return m.checkMemberChange(result, listID, memberID)
+}
+
+// remove a member from a list. Owners can remove any member but the
+// creator of the list, the other members can only leave it. The todos
+// assigned to the member are unassigned.
+func (m *ListModel) RemoveMember(userID, listID, memberID string) error {
+	role, err := m.Role(userID, listID)
+	if err != nil {
+		return err
+	}
+	if memberID != userID && role != RoleOwner {
+		return ErrForbidden
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	stmt := `DELETE FROM list_members
+	WHERE list_id = ? AND user_id = ?
+	AND list_id IN (SELECT id FROM lists WHERE id = ? AND user_id != ?)`
+
+	result, err := tx.Exec(stmt, listID, memberID, listID, memberID)
+	if err != nil {
+		log.Printf("Error while removing a member: %s", err)
+		return err
+	}
+	err = m.checkMemberChange(result, listID, memberID)
+	if err != nil {
+		return err
+	}
+
+	err = unassignMember(tx, userID, listID, memberID)
+	if err != nil {
+		return err
+	}
+
+	err = stopMemberTimers(tx, listID, memberID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkMemberChange tells why a change of membership matched no row:
+// ErrListOwner for the creator of the list, ErrNoRecord otherwise
+func (m *ListModel) checkMemberChange(result sql.Result, listID, memberID string) error {
+	err := checkRowsAffected(result)
+	if !errors.Is(err, ErrNoRecord) {
+		return err
+	}
+
+	var creator bool
+	stmt := `SELECT EXISTS(SELECT true FROM lists WHERE id = ? AND user_id = ?)`
+	err = m.DB.QueryRow(stmt, listID, memberID).Scan(&creator)
+	if err != nil {
+		return err
+	}
+	if creator {
+		return ErrListOwner
+	}
+	return ErrNoRecord
+}
@@ -0,0 +1,258 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"todo-backend.kweeuhree/internal/rank"
+)
+
+// RankRebalanceLength is the rank length above which the positions of the
+// todos of a list are rewritten by RebalancePositions
+const RankRebalanceLength = 16
+
+// maxRankLength is the rank length above which the positions of a list are
+// rewritten right away, it must stay well below the size of the column
+const maxRankLength = 128
+
+// firstPosition returns a rank sorting before all todos of listID
+func firstPosition(tx *sql.Tx, listID string) (string, error) {
+	for attempt := 0; ; attempt++ {
+		var first sql.NullString
+		stmt := `SELECT MIN(position) FROM todos WHERE list_id = ?`
+		err := tx.QueryRow(stmt, listID).Scan(&first)
+		if err != nil {
+			return "", err
+		}
+
+		position, err := rank.Between("", first.String)
+		if err == nil && len(position) <= maxRankLength {
+			return position, nil
+		}
+		if attempt > 0 {
+			return "", errors.New("models: no position left before the first todo")
+		}
+
+		err = rebalanceList(tx, listID)
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// move a todo right after the todo afterID and right before the todo
+// beforeID in the manual order of its list, userID must be an editor of
+// the list. If one of them is empty the todo is moved next to the other
+// one, if both are it is moved to the top. The neighbours must belong to
+// the same list, whose members share the order of its todos.
+func (m *TodoModel) Move(userID, id, afterID, beforeID string) error {
+	if id == afterID || id == beforeID {
+		return ErrInvalidPosition
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	_, listID, err := positionOf(tx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		a, b, err := neighbourPositions(tx, userID, listID, id, afterID, beforeID)
+		if err != nil {
+			return err
+		}
+
+		position, err := rank.Between(a, b)
+		if err == nil && len(position) <= maxRankLength {
+			stmt := `UPDATE todos SET position = ? WHERE id = ? AND ` + writableTodo
+			result, err := tx.Exec(stmt, position, id, userID)
+			if err != nil {
+				log.Printf("Error while moving a todo %s", err)
+				return err
+			}
+			err = checkRowsAffected(result)
+			if err != nil {
+				return err
+			}
+			return tx.Commit()
+		}
+
+		// the neighbours share a position, or the ranks have grown too long:
+		// rewrite the positions of the list once and try again
+		if attempt > 0 {
+			return ErrInvalidPosition
+		}
+		err = rebalanceList(tx, listID)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// neighbourPositions returns the positions between which the todo id of
+// listID is moved, an empty position meaning the start or the end of the
+// order of the list
+func neighbourPositions(tx *sql.Tx, userID, listID, id, afterID, beforeID string) (string, string, error) {
+	var a, b string
+
+	neighbour := func(neighbourID string) (string, error) {
+		position, neighbourListID, err := positionOf(tx, userID, neighbourID)
+		if err != nil {
+			return "", invalidNeighbour(err)
+		}
+		if neighbourListID != listID {
+			return "", ErrInvalidPosition
+		}
+		return position, nil
+	}
+
+	var err error
+	if afterID != "" {
+		a, err = neighbour(afterID)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	if beforeID != "" {
+		b, err = neighbour(beforeID)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	// the other neighbour is the todo of the list which currently follows
+	// afterID, or precedes beforeID, not counting the moved todo
+	var other sql.NullString
+	switch {
+	case beforeID == "":
+		stmt := `SELECT MIN(position) FROM todos WHERE list_id = ? AND position > ? AND id != ? AND deleted_at IS NULL`
+		err = tx.QueryRow(stmt, listID, a, id).Scan(&other)
+		b = other.String
+	case afterID == "":
+		stmt := `SELECT MAX(position) FROM todos WHERE list_id = ? AND position < ? AND id != ? AND deleted_at IS NULL`
+		err = tx.QueryRow(stmt, listID, b, id).Scan(&other)
+		a = other.String
+	case a > b:
+		return "", "", ErrInvalidPosition
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return a, b, nil
+}
+
+// invalidNeighbour reports a missing neighbour as an invalid position,
+// rather than as a missing todo
+func invalidNeighbour(err error) error {
+	if errors.Is(err, ErrNoRecord) {
+		return ErrInvalidPosition
+	}
+	return err
+}
+
+// positionOf returns the position and the list of a live todo userID can
+// read
+func positionOf(tx *sql.Tx, userID, id string) (string, string, error) {
+	var position, listID string
+	stmt := `SELECT position, list_id FROM todos WHERE id = ? AND ` + readableTodo + ` AND deleted_at IS NULL`
+	err := tx.QueryRow(stmt, id, userID).Scan(&position, &listID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", ErrNoRecord
+		}
+		return "", "", err
+	}
+	return position, listID, nil
+}
+
+// rebalanceList rewrites the positions of all todos of listID with short,
+// evenly spread ranks, keeping their order
+func rebalanceList(tx *sql.Tx, listID string) error {
+	stmt := `SELECT id FROM todos WHERE list_id = ? ORDER BY position, id FOR UPDATE`
+	rows, err := tx.Query(stmt, listID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		err = rows.Scan(&id)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	stmt = `UPDATE todos SET position = ? WHERE id = ?`
+	for i, position := range rank.Spread(len(ids)) {
+		_, err = tx.Exec(stmt, position, ids[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewrite the positions of the todos of every list holding a todo with a
+// position longer than maxLength, and return the number of lists whose
+// todos were rewritten
+func (m *TodoModel) RebalancePositions(maxLength int) (int, error) {
+	stmt := `SELECT DISTINCT list_id FROM todos WHERE LENGTH(position) > ?`
+	rows, err := m.DB.Query(stmt, maxLength)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	listIDs := []string{}
+	for rows.Next() {
+		var listID string
+		err = rows.Scan(&listID)
+		if err != nil {
+			return 0, err
+		}
+		listIDs = append(listIDs, listID)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// each list is rewritten in its own transaction, so that
+	// the rows of other lists are never locked for long
+	for i, listID := range listIDs {
+		err = m.rebalance(listID)
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(listIDs), nil
+}
+
+func (m *TodoModel) rebalance(listID string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = rebalanceList(tx, listID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+)
+
+// complete a recurring todo of a list userID is an editor of, or assigned
+// to userID: it is marked as done and stops recurring, and next, its
+// following occurrence, is inserted with the same tags. next is nil once
+// the series is over. If cascade is set, the subtasks of the completed
+// todo are marked as done as well. Unless force is set, ErrBlocked is
+// returned if one of them waits for an open todo.
+func (m *TodoModel) CompleteRecurring(userID, id string, next *Todo, cascade, force bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	err = completeRecurring(tx, userID, id, next, cascade, force)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// completeRecurring completes a recurring todo within tx, see
+// CompleteRecurring
+func completeRecurring(tx *sql.Tx, userID, id string, next *Todo, cascade, force bool) error {
+	ids := []string{id}
+	if cascade {
+		descendants, err := descendantIDs(tx, id)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, descendants...)
+	}
+	if !force {
+		err := checkBlockers(tx, ids)
+		if err != nil {
+			return err
+		}
+	}
+	rr, err := trackRevisions(tx, userID, ActionStatus, ids...)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE todos SET status = true, rrule = '' WHERE id = ? AND ` + completableTodo + ` AND deleted_at IS NULL`
+
+	result, err := tx.Exec(stmt, id, userID)
+	if err != nil {
+		log.Printf("Error while completing a recurring todo %s", err)
+		return err
+	}
+	err = checkRowsAffected(result)
+	if err != nil {
+		return err
+	}
+
+	if cascade {
+		err = setDescendants(tx, id, "status", true)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = syncStates(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	err = stampCompletion(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	err = rr.record()
+	if err != nil {
+		return err
+	}
+
+	if next != nil {
+		return insertOccurrence(tx, userID, id, next)
+	}
+	return nil
+}
+
+// insertOccurrence inserts next, the occurrence following the recurring
+// todo id completed by userID, with the tags of id
+func insertOccurrence(tx *sql.Tx, userID, id string, next *Todo) error {
+	var err error
+	next.UserID, err = occurrenceCreator(tx, next.ListID, userID, next.UserID)
+	if err != nil {
+		return err
+	}
+	err = insertTodo(tx, next)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO todo_tags (todo_id, tag_id) SELECT ?, tag_id FROM todo_tags WHERE todo_id = ?`
+	_, err = tx.Exec(stmt, next.ID, id)
+	return err
+}
+
+// occurrenceCreator returns the user the next occurrence of a series in
+// listID is created by: userID, who completes the series, or else creatorID
+// if they are an editor of the list. Otherwise the creator of the list, who
+// is always one of its owners, takes over the series.
+func occurrenceCreator(tx *sql.Tx, listID, userID, creatorID string) (string, error) {
+	var creator string
+	stmt := `SELECT user_id FROM list_members
+	WHERE list_id = ? AND user_id IN (?, ?) AND role IN ('editor', 'owner')
+	ORDER BY user_id = ? DESC
+	LIMIT 1`
+	err := tx.QueryRow(stmt, listID, userID, creatorID, userID).Scan(&creator)
+	if err == nil {
+		return creator, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	err = tx.QueryRow(`SELECT user_id FROM lists WHERE id = ?`, listID).Scan(&creator)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+	return creator, nil
+}
@@ -0,0 +1,410 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Revision actions
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionStatus   = "status"
+	ActionMove     = "move"
+	ActionReparent = "reparent"
+	ActionAssign   = "assign"
+	ActionState    = "state"
+	ActionDelete   = "delete"
+	ActionRestore  = "restore"
+	ActionRevert   = "revert"
+)
+
+// TodoState is the part of a todo recorded by its revisions. The manual
+// position is left out, as it is rewritten by rebalancing.
+type TodoState struct {
+	Body      string     `json:"body"`
+	Notes     string     `json:"notes"`
+	Status    bool       `json:"status"`
+	StateID   string     `json:"state_id"`
+	StartAt   *time.Time `json:"start_at"`
+	DueAt     *time.Time `json:"due_at"`
+	Priority  int        `json:"priority"`
+	Important bool       `json:"important"`
+	RRule     string     `json:"rrule"`
+	ListID    string     `json:"list_id"`
+	ParentID  string     `json:"parent_id"`
+	// empty for unassigned todos
+	AssigneeID string `json:"assignee_id"`
+	// nil and empty for todos without an estimate
+	Estimate     *int       `json:"estimate"`
+	EstimateUnit string     `json:"estimate_unit"`
+	EstimatedBy  string     `json:"estimated_by"`
+	DeletedAt    *time.Time `json:"deleted_at"`
+}
+
+// Revision is one change of a todo. Before is nil for the creation of the todo.
+type Revision struct {
+	ID        string
+	TodoID    string
+	ActorID   string
+	ActorName string
+	Action    string
+	Before    *TodoState
+	After     *TodoState
+	Created   time.Time
+}
+
+// Change is the before and after value of one field changed by a revision
+type Change struct {
+	Field  string
+	Before any
+	After  any
+}
+
+// Changes returns the fields changed by the revision, in a fixed order
+func (r *Revision) Changes() []Change {
+	before := r.Before
+	if before == nil {
+		before = &TodoState{}
+	}
+	return diffStates(before, r.After)
+}
+
+// diffStates returns the fields which differ between two states
+func diffStates(before, after *TodoState) []Change {
+	changes := []Change{}
+	add := func(field string, changed bool, b, a any) {
+		if changed {
+			changes = append(changes, Change{Field: field, Before: b, After: a})
+		}
+	}
+
+	add("body", before.Body != after.Body, before.Body, after.Body)
+	add("notes", before.Notes != after.Notes, before.Notes, after.Notes)
+	add("status", before.Status != after.Status, before.Status, after.Status)
+	add("state_id", before.StateID != after.StateID, before.StateID, after.StateID)
+	add("start_at", !equalTimes(before.StartAt, after.StartAt), before.StartAt, after.StartAt)
+	add("due_at", !equalTimes(before.DueAt, after.DueAt), before.DueAt, after.DueAt)
+	add("priority", before.Priority != after.Priority, before.Priority, after.Priority)
+	add("important", before.Important != after.Important, before.Important, after.Important)
+	add("rrule", before.RRule != after.RRule, before.RRule, after.RRule)
+	add("list_id", before.ListID != after.ListID, before.ListID, after.ListID)
+	add("parent_id", before.ParentID != after.ParentID, before.ParentID, after.ParentID)
+	add("assignee_id", before.AssigneeID != after.AssigneeID, before.AssigneeID, after.AssigneeID)
+	add("estimate", !equalInts(before.Estimate, after.Estimate), before.Estimate, after.Estimate)
+	add("estimate_unit", before.EstimateUnit != after.EstimateUnit, before.EstimateUnit, after.EstimateUnit)
+	add("estimated_by", before.EstimatedBy != after.EstimatedBy, before.EstimatedBy, after.EstimatedBy)
+	add("deleted_at", !equalTimes(before.DeletedAt, after.DeletedAt), before.DeletedAt, after.DeletedAt)
+
+	return changes
+}
+
+func equalInts(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// readStates reads the current state of the given todos within tx, locking
+// their rows until the end of the transaction
+func readStates(tx *sql.Tx, ids []string) (map[string]*TodoState, error) {
+	states := make(map[string]*TodoState, len(ids))
+	if len(ids) == 0 {
+		return states, nil
+	}
+
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	stmt := `SELECT id, body, notes, status, state_id, start_at, due_at, priority, important, rrule, list_id, parent_id, assignee_id,
+	estimate, estimate_unit, estimated_by, deleted_at
+	FROM todos WHERE id IN (` + placeholders(len(ids)) + `) FOR UPDATE`
+
+	rows, err := tx.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var stateID, listID, parentID, assigneeID, estimateUnit, estimatedBy sql.NullString
+		var startAt, dueAt, deletedAt sql.NullTime
+		var estimate sql.NullInt64
+		s := &TodoState{}
+
+		err = rows.Scan(&id, &s.Body, &s.Notes, &s.Status, &stateID, &startAt, &dueAt, &s.Priority, &s.Important, &s.RRule, &listID, &parentID, &assigneeID,
+			&estimate, &estimateUnit, &estimatedBy, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		s.StartAt = nullTimePtr(startAt)
+		s.DueAt = nullTimePtr(dueAt)
+		s.DeletedAt = nullTimePtr(deletedAt)
+		s.ListID = listID.String
+		s.StateID = stateID.String
+		s.ParentID = parentID.String
+		s.AssigneeID = assigneeID.String
+		s.Estimate = nullIntPtr(estimate)
+		s.EstimateUnit = estimateUnit.String
+		s.EstimatedBy = estimatedBy.String
+		states[id] = s
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// revisionRecorder records the revisions of todos changed within a transaction
+type revisionRecorder struct {
+	tx      *sql.Tx
+	actorID string
+	action  string
+	before  map[string]*TodoState
+}
+
+// trackRevisions reads the state of the todos ids before they are changed by
+// actorID, so that record can store a revision for each of them that changed
+func trackRevisions(tx *sql.Tx, actorID, action string, ids ...string) (*revisionRecorder, error) {
+	before, err := readStates(tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return &revisionRecorder{tx: tx, actorID: actorID, action: action, before: before}, nil
+}
+
+// record stores a revision for each tracked todo whose state changed
+func (rr *revisionRecorder) record() error {
+	ids := make([]string, 0, len(rr.before))
+	for id := range rr.before {
+		ids = append(ids, id)
+	}
+
+	after, err := readStates(rr.tx, ids)
+	if err != nil {
+		return err
+	}
+
+	for id, before := range rr.before {
+		// purged within the transaction, the revisions go with the todo
+		if after[id] == nil {
+			continue
+		}
+		if len(diffStates(before, after[id])) == 0 {
+			continue
+		}
+		err = insertRevision(rr.tx, id, rr.actorID, rr.action, before, after[id])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertRevision stores one revision of a todo
+func insertRevision(tx *sql.Tx, todoID, actorID, action string, before, after *TodoState) error {
+	var beforeJSON []byte
+	if before != nil {
+		js, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		beforeJSON = js
+	}
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO todo_revisions (id, todo_id, actor_id, action, before_state, after_state, created)
+	VALUES (?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(6))`
+
+	_, err = tx.Exec(stmt, uuid.New().String(), todoID, actorID, action, beforeJSON, afterJSON)
+	return err
+}
+
+// recordCreation stores the revision of a todo inserted within tx
+func recordCreation(tx *sql.Tx, actorID, id string) error {
+	after, err := readStates(tx, []string{id})
+	if err != nil {
+		return err
+	}
+	return insertRevision(tx, id, actorID, ActionCreate, nil, after[id])
+}
+
+// return the revisions of a todo of a list userID is a member of, most recent
+// first. The history of a trashed todo can be read as well.
+func (m *TodoModel) History(userID, id string) ([]*Revision, error) {
+	var exists bool
+	stmt := `SELECT EXISTS(SELECT true FROM todos WHERE id = ? AND ` + readableTodo + `)`
+	err := m.DB.QueryRow(stmt, id, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNoRecord
+	}
+
+	stmt = `SELECT r.id, r.todo_id, r.actor_id, COALESCE(u.name, ''), r.action, r.before_state, r.after_state, r.created
+	FROM todo_revisions r
+	LEFT JOIN users u ON u.uuid = r.actor_id
+	WHERE r.todo_id = ?
+	ORDER BY r.created DESC, r.id`
+
+	rows, err := m.DB.Query(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*Revision{}
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// scanRevision copies a row of todo_revisions into a new Revision
+func scanRevision(row scanner) (*Revision, error) {
+	r := &Revision{}
+	var before, after []byte
+
+	err := row.Scan(&r.ID, &r.TodoID, &r.ActorID, &r.ActorName, &r.Action, &before, &after, &r.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	if before != nil {
+		r.Before = &TodoState{}
+		err = json.Unmarshal(before, r.Before)
+		if err != nil {
+			return nil, err
+		}
+	}
+	r.After = &TodoState{}
+	err = json.Unmarshal(after, r.After)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// bring a todo of a list userID is an editor of back to its state as of one
+// of its revisions. The body, notes, status and schedule are restored, as
+// well as the list if it still exists and userID is an editor of it. The
+// parent, the subtasks and the trash are left untouched. The revert itself
+// is recorded as a new revision. Unless force is set, ErrBlocked is
+// returned if the revert completes a todo which waits for an open todo.
+func (m *TodoModel) Revert(userID, id, revisionID string, force bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	stmt := `SELECT r.id, r.todo_id, r.actor_id, '', r.action, r.before_state, r.after_state, r.created
+	FROM todo_revisions r
+	JOIN todos t ON t.id = r.todo_id
+	WHERE r.id = ? AND r.todo_id = ? AND ` + memberOf("t.list_id", RoleEditor) + ` AND t.deleted_at IS NULL`
+
+	revision, err := scanRevision(tx.QueryRow(stmt, revisionID, id, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+	s := revision.After
+
+	var status bool
+	err = tx.QueryRow(`SELECT status FROM todos WHERE id = ? FOR UPDATE`, id).Scan(&status)
+	if err != nil {
+		return err
+	}
+	if s.Status && !status && !force {
+		err = checkBlockers(tx, []string{id})
+		if err != nil {
+			return err
+		}
+	}
+
+	// subtasks always live in the list of their parent
+	ids, err := descendantIDs(tx, id)
+	if err != nil {
+		return err
+	}
+	rr, err := trackRevisions(tx, userID, ActionRevert, append(ids, id)...)
+	if err != nil {
+		return err
+	}
+
+	stmt = `UPDATE todos SET body = ?, notes = ?, status = ?, state_id = ?, start_at = ?, due_at = ?, priority = ?, important = ?, rrule = ?,
+	estimate = ?, estimate_unit = ?, estimated_by = ?,
+	list_id = COALESCE((SELECT l.id FROM lists l WHERE l.id = ? AND ` + memberOf("l.id", RoleEditor) + `), list_id)
+	WHERE id = ?`
+
+	_, err = tx.Exec(stmt, s.Body, s.Notes, s.Status, nullString(s.StateID), s.StartAt, s.DueAt, s.Priority, s.Important, s.RRule,
+		s.Estimate, nullString(s.EstimateUnit), nullString(s.EstimatedBy), s.ListID, userID, id)
+	if err != nil {
+		return err
+	}
+
+	var listID string
+	err = tx.QueryRow(`SELECT list_id FROM todos WHERE id = ?`, id).Scan(&listID)
+	if err != nil {
+		return err
+	}
+	err = setDescendants(tx, id, "list_id", listID)
+	if err != nil {
+		return err
+	}
+
+	err = unassignNonMembers(tx, listID, append(ids, id))
+	if err != nil {
+		return err
+	}
+
+	// the state is restored as long as it still fits the workflow of the list
+	err = syncStates(tx, append(ids, id))
+	if err != nil {
+		return err
+	}
+
+	err = stampCompletion(tx, []string{id})
+	if err != nil {
+		return err
+	}
+
+	err = rr.record()
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"log"
+	"time"
+)
+
+// notSnoozed matches the todos which are not snoozed, or whose snooze has
+// passed
+const notSnoozed = `(todos.hidden_until IS NULL OR todos.hidden_until <= UTC_TIMESTAMP())`
+
+// hide a todo from the default lists until a later time, or show it again
+// if until is nil. userID must be an editor of its list or the assignee of
+// the todo; the todo is hidden from every member of the list.
+func (m *TodoModel) Snooze(userID, id string, until *time.Time) error {
+	stmt := `UPDATE todos SET hidden_until = ? WHERE id = ? AND ` + completableTodo + ` AND deleted_at IS NULL`
+
+	var hiddenUntil any
+	if until != nil {
+		hiddenUntil = until.UTC()
+	}
+	result, err := m.DB.Exec(stmt, hiddenUntil, id, userID)
+	if err != nil {
+		log.Printf("Error while snoozing a todo %s", err)
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
@@ -0,0 +1,495 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+)
+
+// DefaultStates holds the names of the states of a new workflow, in order.
+// The first state is the initial state of new todos, the last one the
+// final state of done todos.
+var DefaultStates = []string{"backlog", "todo", "in progress", "blocked", "done"}
+
+// DefaultTransitions holds the moves allowed between the DefaultStates
+var DefaultTransitions = map[string][]string{
+	"backlog":     {"todo"},
+	"todo":        {"backlog", "in progress"},
+	"in progress": {"todo", "blocked", "done"},
+	"blocked":     {"in progress"},
+	"done":        {"todo"},
+}
+
+// define a state type, states are the steps of the workflow of a user,
+// which the todos of the lists they created go through. The status of a
+// todo is true exactly when it is in the final state of its workflow.
+type State struct {
+	ID       string
+	UserID   string
+	Name     string
+	Position int
+	// the first state of the workflow, given to new and reopened todos
+	Initial bool
+	// the last state of the workflow, given to done todos
+	Final bool
+	// IDs of the states a todo can move to from this one
+	Next    []string
+	Created time.Time
+}
+
+// define a state model type which wraps a sql.DB connection pool
+type StateModel struct {
+	DB *sql.DB
+}
+
+// create the default workflow of a user, unless they already have one
+func (m *StateModel) Defaults(userID string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	var exists bool
+	stmt := `SELECT EXISTS(SELECT true FROM workflow_states WHERE user_id = ?)`
+	err = tx.QueryRow(stmt, userID).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	ids := make(map[string]string, len(DefaultStates))
+	for i, name := range DefaultStates {
+		ids[name] = uuid.New().String()
+
+		stmt = `INSERT INTO workflow_states (id, user_id, name, position, created)
+		VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+		_, err = tx.Exec(stmt, ids[name], userID, name, i+1)
+		if err != nil {
+			return err
+		}
+	}
+	for from, names := range DefaultTransitions {
+		for _, to := range names {
+			_, err = tx.Exec(`INSERT INTO workflow_transitions (from_state_id, to_state_id) VALUES(?, ?)`, ids[from], ids[to])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// return the workflow of a user, in order
+func (m *StateModel) All(userID string) ([]*State, error) {
+	return workflow(m.DB, userID)
+}
+
+// return a state of the workflow of a user
+func (m *StateModel) Get(userID, id string) (*State, error) {
+	states, err := workflow(m.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range states {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return nil, ErrNoRecord
+}
+
+// add a state to the workflow of a user, just before its final state, from
+// which todos can move to the states next, see setTransitions
+func (m *StateModel) Insert(userID, id, name string, next []string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	var position int
+	stmt := `SELECT COALESCE(MAX(position), 1) FROM workflow_states WHERE user_id = ? FOR UPDATE`
+	err = tx.QueryRow(stmt, userID).Scan(&position)
+	if err != nil {
+		return err
+	}
+
+	// the final state moves down one step to make room
+	stmt = `UPDATE workflow_states SET position = position + 1 WHERE user_id = ? AND position = ?`
+	_, err = tx.Exec(stmt, userID, position)
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO workflow_states (id, user_id, name, position, created)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+	_, err = tx.Exec(stmt, id, userID, name, position)
+	if err != nil {
+		return duplicateStateError(err)
+	}
+
+	err = setTransitions(tx, userID, id, next)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// rename a state of the workflow of a user and replace the states todos can
+// move to from it, see setTransitions
+func (m *StateModel) Update(userID, id, name string, next []string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE workflow_states SET name = ? WHERE id = ? AND user_id = ?`, name, id, userID)
+	if err != nil {
+		return duplicateStateError(err)
+	}
+	err = checkRowsAffected(result)
+	if err != nil {
+		return err
+	}
+
+	err = setTransitions(tx, userID, id, next)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setTransitions replaces the states todos can move to from the state id.
+// ErrUnknownState is returned if next holds a state of another workflow.
+func setTransitions(tx *sql.Tx, userID, id string, next []string) error {
+	_, err := tx.Exec(`DELETE FROM workflow_transitions WHERE from_state_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	added := map[string]bool{id: true}
+	for _, to := range next {
+		if added[to] {
+			continue
+		}
+		added[to] = true
+
+		stmt := `INSERT INTO workflow_transitions (from_state_id, to_state_id)
+		SELECT ?, id FROM workflow_states WHERE id = ? AND user_id = ?`
+		result, err := tx.Exec(stmt, id, to, userID)
+		if err != nil {
+			return err
+		}
+		err = checkRowsAffected(result)
+		if errors.Is(err, ErrNoRecord) {
+			return ErrUnknownState
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delete a state of the workflow of a user. The initial and final states
+// cannot be deleted, nor the states todos are in.
+func (m *StateModel) Delete(userID, id string) error {
+	s, err := m.Get(userID, id)
+	if err != nil {
+		return err
+	}
+	if s.Initial || s.Final {
+		return ErrFixedState
+	}
+
+	// the transitions go with the state
+	stmt := `DELETE FROM workflow_states
+	WHERE id = ? AND user_id = ? AND NOT EXISTS(SELECT true FROM todos WHERE state_id = ?)`
+
+	result, err := m.DB.Exec(stmt, id, userID, id)
+	if err != nil {
+		log.Printf("Error while deleting a state: %s", err)
+		return err
+	}
+	err = checkRowsAffected(result)
+	if errors.Is(err, ErrNoRecord) {
+		return ErrStateInUse
+	}
+	return err
+}
+
+// workflow reads the states of a user, in order, with their transitions
+func workflow(q querier, userID string) ([]*State, error) {
+	stmt := `SELECT id, user_id, name, position, created FROM workflow_states
+	WHERE user_id = ?
+	ORDER BY position`
+
+	rows, err := q.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := []*State{}
+	byID := map[string]*State{}
+	for rows.Next() {
+		s := &State{Next: []string{}}
+		err = rows.Scan(&s.ID, &s.UserID, &s.Name, &s.Position, &s.Created)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, s)
+		byID[s.ID] = s
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return states, nil
+	}
+	states[0].Initial = true
+	states[len(states)-1].Final = true
+
+	stmt = `SELECT wt.from_state_id, wt.to_state_id FROM workflow_transitions wt
+	JOIN workflow_states ws ON ws.id = wt.to_state_id
+	WHERE ws.user_id = ?
+	ORDER BY ws.position`
+
+	rows, err = q.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var from, to string
+		err = rows.Scan(&from, &to)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := byID[from]; ok {
+			s.Next = append(s.Next, to)
+		}
+	}
+
+	return states, rows.Err()
+}
+
+// move a todo to another state of the workflow of the creator of its list,
+// userID must be an editor of the list or the assignee of the todo. The
+// move must be one of the transitions of the current state, and its status
+// follows: the todo is done exactly when it reaches the final state. Unless
+// force is set, ErrBlocked is returned if it waits for an open todo then.
+// A recurring todo reaching the final state stops recurring and next, its
+// following occurrence, is inserted as by CompleteRecurring; next is nil
+// once the series is over. The returned flag reports whether next was
+// inserted.
+func (m *TodoModel) SetState(userID, id, stateID string, next *Todo, force bool) (bool, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	var current sql.NullString
+	var owner, rrule string
+	var status bool
+	stmt := `SELECT todos.state_id, todos.status, todos.rrule, l.user_id FROM todos
+	JOIN lists l ON l.id = todos.list_id
+	WHERE todos.id = ? AND ` + completableTodo + ` AND todos.deleted_at IS NULL
+	FOR UPDATE`
+	err = tx.QueryRow(stmt, id, userID).Scan(&current, &status, &rrule, &owner)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrNoRecord
+		}
+		return false, err
+	}
+
+	states, err := workflow(tx, owner)
+	if err != nil {
+		return false, err
+	}
+	var target, from *State
+	for _, s := range states {
+		if s.ID == stateID {
+			target = s
+		}
+		if s.ID == current.String {
+			from = s
+		}
+	}
+	if target == nil {
+		return false, ErrUnknownState
+	}
+	// todos without a state yet can be moved anywhere
+	if from != nil && from != target && !slices.Contains(from.Next, target.ID) {
+		return false, ErrIllegalTransition
+	}
+	if target.Final && !force {
+		err = checkBlockers(tx, []string{id})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	rr, err := trackRevisions(tx, userID, ActionState, id)
+	if err != nil {
+		return false, err
+	}
+
+	// completing a recurring todo ends it, the series goes on with next
+	recurs := target.Final && !status && rrule != ""
+	if recurs {
+		rrule = ""
+	}
+	_, err = tx.Exec(`UPDATE todos SET state_id = ?, status = ?, rrule = ? WHERE id = ?`, target.ID, target.Final, rrule, id)
+	if err != nil {
+		log.Printf("Error while changing the state of a todo %s", err)
+		return false, err
+	}
+
+	err = stampCompletion(tx, []string{id})
+	if err != nil {
+		return false, err
+	}
+
+	err = rr.record()
+	if err != nil {
+		return false, err
+	}
+
+	inserted := recurs && next != nil
+	if inserted {
+		err = insertOccurrence(tx, userID, id, next)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return inserted, tx.Commit()
+}
+
+// KanbanColumn holds the todos in one state of a workflow
+type KanbanColumn struct {
+	State *State
+	Todos []*Todo
+}
+
+// return the live todos userID can read in the lists created by owner,
+// grouped by the states of the workflow of owner, in order. The todos of a
+// single list are returned if listID is set, owner being its creator.
+func (m *TodoModel) Kanban(userID, owner, listID string) ([]*KanbanColumn, error) {
+	states, err := workflow(m.DB, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `SELECT ` + todoColumns + ` FROM todos
+	WHERE ` + readableTodo + ` AND deleted_at IS NULL
+	AND state_id IN (SELECT id FROM workflow_states WHERE user_id = ?)`
+	args := []any{userID, owner}
+	if listID != "" {
+		stmt += ` AND list_id = ?`
+		args = append(args, listID)
+	}
+	stmt += ` ORDER BY position, id`
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make([]*KanbanColumn, 0, len(states))
+	byState := make(map[string]*KanbanColumn, len(states))
+	for _, s := range states {
+		c := &KanbanColumn{State: s, Todos: []*Todo{}}
+		columns = append(columns, c)
+		byState[s.ID] = c
+	}
+	todos := []*Todo{}
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := byState[t.StateID]; ok {
+			c.Todos = append(c.Todos, t)
+			todos = append(todos, t)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = m.loadTags(todos)
+	if err != nil {
+		return nil, err
+	}
+
+	return columns, nil
+}
+
+// syncStates keeps the todos ids in the workflow of the creator of their
+// list and their state in line with their status, after either changed:
+// a state of another workflow is replaced by the state of the same name,
+// done todos are moved to the final state, and open todos out of it, or
+// todos without a state, to the initial state
+func syncStates(tx *sql.Tx, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	stmt := `UPDATE todos
+	JOIN lists l ON l.id = todos.list_id
+	LEFT JOIN workflow_states cur ON cur.id = todos.state_id
+	SET todos.state_id = (SELECT ws.id FROM workflow_states ws WHERE ws.user_id = l.user_id AND ws.name = cur.name)
+	WHERE todos.id IN (` + placeholders(len(ids)) + `) AND NOT (cur.user_id <=> l.user_id)`
+
+	_, err := tx.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	stmt = `UPDATE todos
+	JOIN lists l ON l.id = todos.list_id
+	SET todos.state_id = (SELECT ws.id FROM workflow_states ws WHERE ws.user_id = l.user_id
+		ORDER BY IF(todos.status, -ws.position, ws.position) LIMIT 1)
+	WHERE todos.id IN (` + placeholders(len(ids)) + `) AND (todos.state_id IS NULL
+		OR todos.status != (todos.state_id <=> (SELECT ws.id FROM workflow_states ws WHERE ws.user_id = l.user_id
+			ORDER BY ws.position DESC LIMIT 1)))`
+
+	_, err = tx.Exec(stmt, args...)
+	return err
+}
+
+// duplicateStateError translates a violation of the
+// workflow_states_uc_user_name key into ErrDuplicateName
+func duplicateStateError(err error) error {
+	var mySQLError *mysql.MySQLError
+	if errors.As(err, &mySQLError) {
+		if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "workflow_states_uc_user_name") {
+			return ErrDuplicateName
+		}
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Stats sums up the todos of the lists a user is a member of over a range
+// of days
+type Stats struct {
+	// number of todos completed on each day of the range, in order
+	CompletedPerDay []DayCount
+	// number of todos completed over the range
+	Completed int
+	// average time from the creation to the completion of the todos
+	// completed over the range, nil if none was
+	AverageCompletion *time.Duration
+	// longest run of consecutive days with at least one completion, and
+	// the run ending on the last day of the range or the day before
+	LongestStreak int
+	CurrentStreak int
+	// todos created over the range which are still open, or done
+	Open int
+	Done int
+}
+
+// DayCount is the number of todos completed on a day
+type DayCount struct {
+	// the day, as a date at midnight UTC
+	Date  time.Time
+	Count int
+}
+
+// return the statistics of the todos of the lists userID is a member of,
+// from the day of from to the day of to included. Days are those of the
+// time zone of from and to. Trashed todos are left out.
+func (m *TodoModel) Stats(userID string, from, to time.Time) (*Stats, error) {
+	// completions are counted on the day of the time zone of the user
+	cal, args := calendar(from, to)
+	args = append(args, userID)
+	start := from.UTC()
+	end := to.AddDate(0, 0, 1).UTC()
+
+	perDay := `WITH ` + cal + `,
+	per_day AS (
+		SELECT c.day, COUNT(todos.id) AS completed FROM calendar c
+		LEFT JOIN todos ON todos.completed_at >= c.start_at AND todos.completed_at < c.end_at
+		AND ` + readableTodo + ` AND todos.deleted_at IS NULL
+		GROUP BY c.day
+	)`
+
+	stats := &Stats{CompletedPerDay: []DayCount{}}
+
+	rows, err := m.DB.Query(perDay+` SELECT day, completed FROM per_day ORDER BY day`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d DayCount
+		err = rows.Scan(&d.Date, &d.Count)
+		if err != nil {
+			return nil, err
+		}
+		stats.CompletedPerDay = append(stats.CompletedPerDay, d)
+		stats.Completed += d.Count
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// streaks are the islands of consecutive days with completions: the
+	// days of an island are all the same number of days after their rank
+	stmt := perDay + `,
+	islands AS (
+		SELECT day, DATE_SUB(day, INTERVAL ROW_NUMBER() OVER (ORDER BY day) DAY) AS island
+		FROM per_day WHERE completed > 0
+	),
+	streaks AS (
+		SELECT COUNT(*) AS length, MAX(day) AS last_day FROM islands GROUP BY island
+	)
+	SELECT COALESCE(MAX(length), 0), COALESCE(MAX(CASE WHEN last_day >= CAST(? AS DATE) THEN length END), 0)
+	FROM streaks`
+
+	args = append(args, to.AddDate(0, 0, -1).Format(time.DateOnly))
+	err = m.DB.QueryRow(stmt, args...).Scan(&stats.LongestStreak, &stats.CurrentStreak)
+	if err != nil {
+		return nil, err
+	}
+
+	var average sql.NullFloat64
+	stmt = `SELECT AVG(TIMESTAMPDIFF(SECOND, created, completed_at)) FROM todos
+	WHERE completed_at >= ? AND completed_at < ? AND ` + readableTodo + ` AND deleted_at IS NULL`
+
+	err = m.DB.QueryRow(stmt, start, end, userID).Scan(&average)
+	if err != nil {
+		return nil, err
+	}
+	if average.Valid {
+		d := time.Duration(average.Float64) * time.Second
+		stats.AverageCompletion = &d
+	}
+
+	stmt = `SELECT COALESCE(SUM(status = false), 0), COALESCE(SUM(status = true), 0) FROM todos
+	WHERE created >= ? AND created < ? AND ` + readableTodo + ` AND deleted_at IS NULL`
+
+	err = m.DB.QueryRow(stmt, start, end, userID).Scan(&stats.Open, &stats.Done)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// calendar returns a common table expression named calendar with one row
+// per day from the day of from to the day of to included, and its
+// arguments. Each row holds the day and its bounds in UTC, so that times
+// stored in UTC fall on the days of the time zone of from and to.
+func calendar(from, to time.Time) (string, []any) {
+	days := []string{}
+	args := []any{}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		days = append(days, `SELECT CAST(? AS DATE), ?, ?`)
+		args = append(args, day.Format(time.DateOnly), day.UTC(), day.AddDate(0, 0, 1).UTC())
+	}
+	return `calendar (day, start_at, end_at) AS (` + strings.Join(days, ` UNION ALL `) + `)`, args
+}
@@ -0,0 +1,234 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+)
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// descendantIDs returns the IDs of all live subtasks of a todo, at any depth
+func descendantIDs(q querier, id string) ([]string, error) {
+	stmt := `WITH RECURSIVE subtree (id) AS (
+		SELECT id FROM todos WHERE parent_id = ? AND deleted_at IS NULL
+		UNION ALL
+		SELECT c.id FROM todos c JOIN subtree s ON c.parent_id = s.id
+		WHERE c.deleted_at IS NULL
+	)
+	SELECT id FROM subtree`
+
+	rows, err := q.Query(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var childID string
+		err = rows.Scan(&childID)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, childID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// setDescendants sets a column of all subtasks of a todo to value. The column
+// name must come from the caller, never from user input.
+func setDescendants(q querier, id, column string, value any) error {
+	ids, err := descendantIDs(q, id)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	args := []any{value}
+	for _, childID := range ids {
+		args = append(args, childID)
+	}
+
+	stmt := `UPDATE todos SET ` + column + ` = ? WHERE id IN (` + placeholders(len(ids)) + `)`
+
+	_, err = q.Exec(stmt, args...)
+	return err
+}
+
+// return a todo together with all of its subtasks, at any depth. The root
+// comes first, the subtasks follow in creation order and can be nested
+// using their ParentID.
+func (m *TodoModel) Tree(userID, id string) ([]*Todo, error) {
+	root, err := m.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := descendantIDs(m.DB, id)
+	if err != nil {
+		return nil, err
+	}
+
+	todos := []*Todo{root}
+	if len(ids) == 0 {
+		return todos, nil
+	}
+
+	args := []any{userID}
+	for _, childID := range ids {
+		args = append(args, childID)
+	}
+
+	stmt := `SELECT ` + todoColumns + ` FROM todos
+	WHERE ` + readableTodo + ` AND id IN (` + placeholders(len(ids)) + `)
+	ORDER BY created`
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = m.loadTags(todos[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// move a todo under another parent, or to the top level if parentID is
+// empty. A todo cannot be nested under itself or one of its own subtasks.
+// The todo and its subtasks follow the parent to its list, userID must be
+// an editor of both lists.
+func (m *TodoModel) Reparent(userID, id, parentID string) error {
+	if parentID == id {
+		return ErrCycle
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	err = lockAncestors(tx, id, parentID)
+	if err != nil {
+		return err
+	}
+
+	ids, err := descendantIDs(tx, id)
+	if err != nil {
+		return err
+	}
+
+	rr, err := trackRevisions(tx, userID, ActionReparent, append(ids, id)...)
+	if err != nil {
+		return err
+	}
+
+	if parentID == "" {
+		stmt := `UPDATE todos SET parent_id = NULL WHERE id = ? AND ` + writableTodo + ` AND deleted_at IS NULL`
+		result, err := tx.Exec(stmt, id, userID)
+		if err != nil {
+			return err
+		}
+		err = checkRowsAffected(result)
+		if err != nil {
+			return err
+		}
+	} else {
+		var listID string
+		stmt := `SELECT list_id FROM todos WHERE id = ? AND ` + writableTodo + ` AND deleted_at IS NULL`
+		err = tx.QueryRow(stmt, parentID, userID).Scan(&listID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNoRecord
+			}
+			return err
+		}
+
+		stmt = `UPDATE todos SET parent_id = ?, list_id = ? WHERE id = ? AND ` + writableTodo + ` AND deleted_at IS NULL`
+		result, err := tx.Exec(stmt, parentID, listID, id, userID)
+		if err != nil {
+			log.Printf("Error while moving a todo under another parent %s", err)
+			return err
+		}
+		err = checkRowsAffected(result)
+		if err != nil {
+			return err
+		}
+
+		err = setDescendants(tx, id, "list_id", listID)
+		if err != nil {
+			return err
+		}
+
+		err = unassignNonMembers(tx, listID, append(ids, id))
+		if err != nil {
+			return err
+		}
+
+		err = syncStates(tx, append(ids, id))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rr.record()
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockAncestors locks a todo and parentID with all of its ancestors until
+// the end of tx, and returns ErrCycle if the todo is one of them. Holding
+// the locks keeps concurrent moves from closing a cycle once checked.
+func lockAncestors(tx *sql.Tx, id, parentID string) error {
+	var parent sql.NullString
+	err := tx.QueryRow(`SELECT parent_id FROM todos WHERE id = ? FOR UPDATE`, id).Scan(&parent)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	for ancestor := parentID; ancestor != ""; ancestor = parent.String {
+		if ancestor == id {
+			return ErrCycle
+		}
+		err = tx.QueryRow(`SELECT parent_id FROM todos WHERE id = ? FOR UPDATE`, ancestor).Scan(&parent)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNoRecord
+			}
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// define a tag type, tags are named and colored labels
+// that can be attached to any number of todos
+type Tag struct {
+	ID      string
+	UserID  string
+	Name    string
+	Color   string
+	Created time.Time
+}
+
+// define a tag model type which wraps a sql.DB connection pool
+type TagModel struct {
+	DB *sql.DB
+}
+
+// insert a new tag into the database, t.ID and t.UserID must already be set
+func (m *TagModel) Insert(t *Tag) (string, error) {
+	stmt := `INSERT INTO tags (id, user_id, name, color, created)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, t.ID, t.UserID, t.Name, t.Color)
+	if err != nil {
+		return "", duplicateTagError(err)
+	}
+
+	return t.ID, nil
+}
+
+// return a specific tag based on its id, as long as it belongs to userID
+func (m *TagModel) Get(userID, id string) (*Tag, error) {
+	stmt := `SELECT id, user_id, name, color, created FROM tags
+	WHERE id = ? AND user_id = ?`
+
+	t := &Tag{}
+	err := m.DB.QueryRow(stmt, id, userID).Scan(&t.ID, &t.UserID, &t.Name, &t.Color, &t.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// return all tags created by userID, sorted by name
+func (m *TagModel) All(userID string) ([]*Tag, error) {
+	stmt := `SELECT id, user_id, name, color, created FROM tags
+	WHERE user_id = ?
+	ORDER BY name`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+	for rows.Next() {
+		t := &Tag{}
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Color, &t.Created)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// rename and/or recolor a tag
+func (m *TagModel) Update(userID string, t *Tag) error {
+	stmt := `UPDATE tags SET name = ?, color = ? WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, t.Name, t.Color, t.ID, userID)
+	if err != nil {
+		return duplicateTagError(err)
+	}
+
+	return checkRowsAffected(result)
+}
+
+// delete a tag, the todo_tags foreign key detaches it from every todo
+func (m *TagModel) Delete(userID, id string) error {
+	stmt := `DELETE FROM tags WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// attach a tag of userID to a todo of a list they are an editor of.
+// Attaching a tag twice is not an error.
+func (m *TagModel) Attach(userID, todoID, tagID string) error {
+	err := m.checkOwnership(userID, todoID, tagID)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT IGNORE INTO todo_tags (todo_id, tag_id) VALUES(?, ?)`
+
+	_, err = m.DB.Exec(stmt, todoID, tagID)
+	return err
+}
+
+// detach a tag of userID from a todo of a list they are an editor of
+func (m *TagModel) Detach(userID, todoID, tagID string) error {
+	err := m.checkOwnership(userID, todoID, tagID)
+	if err != nil {
+		return err
+	}
+
+	stmt := `DELETE FROM todo_tags WHERE todo_id = ? AND tag_id = ?`
+
+	_, err = m.DB.Exec(stmt, todoID, tagID)
+	return err
+}
+
+// checkOwnership returns ErrNoRecord unless userID can change the todo
+// and owns the tag
+func (m *TagModel) checkOwnership(userID, todoID, tagID string) error {
+	var owned bool
+	stmt := `SELECT EXISTS(SELECT true FROM todos WHERE id = ? AND ` + writableTodo + ` AND deleted_at IS NULL)
+	AND EXISTS(SELECT true FROM tags WHERE id = ? AND user_id = ?)`
+
+	err := m.DB.QueryRow(stmt, todoID, userID, tagID, userID).Scan(&owned)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// duplicateTagError translates a violation of the tags_uc_user_name key into
+// ErrDuplicateName
+func duplicateTagError(err error) error {
+	var mySQLError *mysql.MySQLError
+	if errors.As(err, &mySQLError) {
+		if mySQLError.Number == 1062 &&
+			strings.Contains(mySQLError.Message, "tags_uc_user_name") {
+			return ErrDuplicateName
+		}
+	}
+	return err
+}
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// define a template type, a named checklist of todos a user creates again
+// and again
+type Template struct {
+	ID      string
+	UserID  string
+	Name    string
+	Created time.Time
+	// the todos to create, parents before their subtasks
+	Items []*TemplateItem
+}
+
+// define a template item type, a todo of a template
+type TemplateItem struct {
+	// index of the parent of the item in the items of the template, nil for
+	// top-level items
+	Parent    *int
+	Body      string
+	Notes     string
+	Priority  int
+	Important bool
+	// schedule of the todo relative to the time the template is
+	// instantiated for, nil for todos without such a date
+	StartOffset *time.Duration
+	DueOffset   *time.Duration
+}
+
+// define a template model type which wraps a sql.DB connection pool
+type TemplateModel struct {
+	DB *sql.DB
+}
+
+// insert a new template with its items, t.ID and t.UserID must already be
+// set
+func (m *TemplateModel) Insert(t *Template) (string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO templates (id, user_id, name, created) VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	_, err = tx.Exec(stmt, t.ID, t.UserID, t.Name)
+	if err != nil {
+		return "", duplicateTemplateError(err)
+	}
+
+	stmt = `INSERT INTO template_items (template_id, position, parent_position, body, notes, priority, important, start_offset, due_offset)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	for i, item := range t.Items {
+		_, err = tx.Exec(stmt, t.ID, i, item.Parent, item.Body, item.Notes, item.Priority, item.Important,
+			offsetSeconds(item.StartOffset), offsetSeconds(item.DueOffset))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+
+	return t.ID, nil
+}
+
+// return a specific template with its items, as long as it belongs to
+// userID
+func (m *TemplateModel) Get(userID, id string) (*Template, error) {
+	stmt := `SELECT id, user_id, name, created FROM templates
+	WHERE id = ? AND user_id = ?`
+
+	t := &Template{}
+	err := m.DB.QueryRow(stmt, id, userID).Scan(&t.ID, &t.UserID, &t.Name, &t.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	err = m.loadItems([]*Template{t})
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// return all templates created by userID with their items, sorted by name
+func (m *TemplateModel) All(userID string) ([]*Template, error) {
+	stmt := `SELECT id, user_id, name, created FROM templates
+	WHERE user_id = ?
+	ORDER BY name`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []*Template{}
+	for rows.Next() {
+		t := &Template{}
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Created)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = m.loadItems(templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+// delete a template, the template_items foreign key deletes its items
+func (m *TemplateModel) Delete(userID, id string) error {
+	stmt := `DELETE FROM templates WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// loadItems fills in the items of the given templates with a single query
+func (m *TemplateModel) loadItems(templates []*Template) error {
+	if len(templates) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]*Template, len(templates))
+	args := make([]any, 0, len(templates))
+	for _, t := range templates {
+		t.Items = []*TemplateItem{}
+		byID[t.ID] = t
+		args = append(args, t.ID)
+	}
+
+	stmt := `SELECT template_id, parent_position, body, notes, priority, important, start_offset, due_offset
+	FROM template_items
+	WHERE template_id IN (` + placeholders(len(templates)) + `)
+	ORDER BY template_id, position`
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var templateID string
+		var parent, startOffset, dueOffset sql.NullInt64
+		item := &TemplateItem{}
+		err = rows.Scan(&templateID, &parent, &item.Body, &item.Notes, &item.Priority, &item.Important, &startOffset, &dueOffset)
+		if err != nil {
+			return err
+		}
+		item.Parent = nullIntPtr(parent)
+		item.StartOffset = nullDurationPtr(startOffset)
+		item.DueOffset = nullDurationPtr(dueOffset)
+		if t, ok := byID[templateID]; ok {
+			t.Items = append(t.Items, item)
+		}
+	}
+
+	return rows.Err()
+}
+
+// offsetSeconds converts an optional offset to the seconds it is stored as
+func offsetSeconds(d *time.Duration) sql.NullInt64 {
+	if d == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(d.Seconds()), Valid: true}
+}
+
+// nullDurationPtr converts an optional number of seconds to a duration
+func nullDurationPtr(n sql.NullInt64) *time.Duration {
+	if !n.Valid {
+		return nil
+	}
+	d := time.Duration(n.Int64) * time.Second
+	return &d
+}
+
+// duplicateTemplateError translates a violation of the
+// templates_uc_user_name key into ErrDuplicateName
+func duplicateTemplateError(err error) error {
+	var mySQLError *mysql.MySQLError
+	if errors.As(err, &mySQLError) {
+		if mySQLError.Number == 1062 &&
+			strings.Contains(mySQLError.Message, "templates_uc_user_name") {
+			return ErrDuplicateName
+		}
+	}
+	return err
+}
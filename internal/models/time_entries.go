@@ -0,0 +1,412 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// define a time entry type, an interval a user spent working on a todo.
+// Entries are recorded by timers, or added by hand.
+type TimeEntry struct {
+	ID     string
+	TodoID string
+	// ID and name of the user who tracked the time
+	UserID    string
+	UserName  string
+	StartedAt time.Time
+	// nil while the timer of the entry is running
+	StoppedAt *time.Time
+	Created   time.Time
+}
+
+// Duration returns the time tracked by the entry, up to now if its timer
+// is still running
+func (e *TimeEntry) Duration() time.Duration {
+	if e.StoppedAt == nil {
+		return time.Since(e.StartedAt)
+	}
+	return e.StoppedAt.Sub(e.StartedAt)
+}
+
+// TimeReport sums up the time tracked on the todos of the lists a user is
+// a member of over a range of days
+type TimeReport struct {
+	// time tracked on each todo, the most tracked first
+	PerTodo []TodoTime
+	// time tracked on each day of the range, in order
+	PerDay []DayTime
+	Total  time.Duration
+}
+
+// TodoTime is the time tracked on a todo
+type TodoTime struct {
+	TodoID   string
+	Body     string
+	Duration time.Duration
+}
+
+// DayTime is the time tracked on a day
+type DayTime struct {
+	// the day, as a date at midnight UTC
+	Date     time.Time
+	Duration time.Duration
+}
+
+// define a time entry model type which wraps a sql.DB connection pool
+type TimeEntryModel struct {
+	DB *sql.DB
+}
+
+// timeEntryColumns lists the columns read by scanTimeEntry, in scan order
+const timeEntryColumns = `te.id, te.todo_id, te.user_id, COALESCE(u.name, ''), te.started_at, te.stopped_at, te.created`
+
+// scanTimeEntry copies a row selected with timeEntryColumns into a new
+// TimeEntry
+func scanTimeEntry(row scanner) (*TimeEntry, error) {
+	e := &TimeEntry{}
+	var stoppedAt sql.NullTime
+	err := row.Scan(&e.ID, &e.TodoID, &e.UserID, &e.UserName, &e.StartedAt, &stoppedAt, &e.Created)
+	if err != nil {
+		return nil, err
+	}
+	e.StoppedAt = nullTimePtr(stoppedAt)
+	return e, nil
+}
+
+// start a timer of userID on a live todo they can work on: a todo of a list
+// they are an editor of, or assigned to them. A user has at most one
+// running timer, ErrTimerRunning is returned if they already have one.
+func (m *TimeEntryModel) Start(userID, todoID, id string) error {
+	return m.Insert(userID, &TimeEntry{ID: id, TodoID: todoID, StartedAt: time.Now().UTC()})
+}
+
+// stop the running timer of userID on a todo and return its entry.
+// ErrNoTimer is returned if their running timer, if any, is on another
+// todo. Users can always stop their timers, even on todos they cannot work
+// on anymore.
+func (m *TimeEntryModel) Stop(userID, todoID string) (*TimeEntry, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	var id string
+	stmt := `SELECT id FROM time_entries WHERE user_id = ? AND todo_id = ? AND stopped_at IS NULL FOR UPDATE`
+	err = tx.QueryRow(stmt, userID, todoID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoTimer
+		}
+		return nil, err
+	}
+
+	// timers are started by the clock of the application, which may be ahead
+	// of that of the database
+	stmt = `UPDATE time_entries SET stopped_at = GREATEST(started_at, UTC_TIMESTAMP(6)) WHERE id = ?`
+	_, err = tx.Exec(stmt, id)
+	if err != nil {
+		log.Printf("Error while stopping a timer: %s", err)
+		return nil, err
+	}
+
+	stmt = `SELECT ` + timeEntryColumns + ` FROM time_entries te
+	LEFT JOIN users u ON u.uuid = te.user_id
+	WHERE te.id = ?`
+
+	e, err := scanTimeEntry(tx.QueryRow(stmt, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return e, tx.Commit()
+}
+
+// return the running timer of userID, ErrNoRecord is returned if they have
+// none
+func (m *TimeEntryModel) Running(userID string) (*TimeEntry, error) {
+	stmt := `SELECT ` + timeEntryColumns + ` FROM time_entries te
+	LEFT JOIN users u ON u.uuid = te.user_id
+	WHERE te.user_id = ? AND te.stopped_at IS NULL`
+
+	e, err := scanTimeEntry(m.DB.QueryRow(stmt, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// insert an entry of userID added by hand on a live todo they can work on,
+// e.ID, e.TodoID and its times must already be set. An entry without a stop
+// time is a running timer, ErrTimerRunning is returned if userID already
+// has one.
+func (m *TimeEntryModel) Insert(userID string, e *TimeEntry) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	if e.StoppedAt == nil {
+		err = checkNoTimer(tx, userID, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	stmt := `INSERT INTO time_entries (id, todo_id, user_id, started_at, stopped_at, created)
+	SELECT ?, id, ?, ?, ?, UTC_TIMESTAMP(6) FROM todos
+	WHERE id = ? AND ` + completableTodo + ` AND deleted_at IS NULL`
+
+	result, err := tx.Exec(stmt, e.ID, userID, e.StartedAt, e.StoppedAt, e.TodoID, userID)
+	if err != nil {
+		log.Printf("Error while inserting a time entry: %s", err)
+		return runningTimerError(err)
+	}
+	err = checkRowsAffected(result)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// return an entry on a live todo of a list userID is a member of
+func (m *TimeEntryModel) Get(userID, todoID, id string) (*TimeEntry, error) {
+	stmt := `SELECT ` + timeEntryColumns + ` FROM time_entries te
+	JOIN todos t ON t.id = te.todo_id
+	LEFT JOIN users u ON u.uuid = te.user_id
+	WHERE te.id = ? AND te.todo_id = ? AND ` + memberOf("t.list_id", RoleViewer) + ` AND t.deleted_at IS NULL`
+
+	e, err := scanTimeEntry(m.DB.QueryRow(stmt, id, todoID, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// return the entries of all the members on a live todo of a list userID is
+// a member of, the latest first
+func (m *TimeEntryModel) All(userID, todoID string) ([]*TimeEntry, error) {
+	var exists bool
+	stmt := `SELECT EXISTS(SELECT true FROM todos WHERE id = ? AND ` + readableTodo + ` AND deleted_at IS NULL)`
+	err := m.DB.QueryRow(stmt, todoID, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNoRecord
+	}
+
+	stmt = `SELECT ` + timeEntryColumns + ` FROM time_entries te
+	LEFT JOIN users u ON u.uuid = te.user_id
+	WHERE te.todo_id = ?
+	ORDER BY te.started_at DESC, te.id`
+
+	rows, err := m.DB.Query(stmt, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*TimeEntry{}
+	for rows.Next() {
+		e, err := scanTimeEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// change the times of an entry of userID on a live todo they can work on.
+// Clearing the stop time restarts the timer of the entry, ErrTimerRunning
+// is returned if userID already has another running timer.
+func (m *TimeEntryModel) Update(userID, todoID, id string, startedAt time.Time, stoppedAt *time.Time) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	if stoppedAt == nil {
+		err = checkNoTimer(tx, userID, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	stmt := `UPDATE time_entries SET started_at = ?, stopped_at = ?
+	WHERE id = ? AND todo_id = ? AND user_id = ?
+	AND todo_id IN (SELECT id FROM todos WHERE ` + completableTodo + ` AND deleted_at IS NULL)`
+
+	result, err := tx.Exec(stmt, startedAt, stoppedAt, id, todoID, userID, userID)
+	if err != nil {
+		log.Printf("Error while updating a time entry: %s", err)
+		return runningTimerError(err)
+	}
+	err = checkRowsAffected(result)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// delete an entry of userID on a live todo they can work on
+func (m *TimeEntryModel) Delete(userID, todoID, id string) error {
+	stmt := `DELETE FROM time_entries
+	WHERE id = ? AND todo_id = ? AND user_id = ?
+	AND todo_id IN (SELECT id FROM todos WHERE ` + completableTodo + ` AND deleted_at IS NULL)`
+
+	result, err := m.DB.Exec(stmt, id, todoID, userID, userID)
+	if err != nil {
+		log.Printf("Error while deleting a time entry: %s", err)
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// return the time tracked by all the members on the live todos of the lists
+// userID is a member of, or of one list if listID is not empty, from the
+// day of from to the day of to included. Days are those of the time zone of
+// from and to, entries spanning midnight are split between their days and
+// running timers count up to now.
+func (m *TimeEntryModel) Report(userID, listID string, from, to time.Time) (*TimeReport, error) {
+	cal, args := calendar(from, to)
+
+	// the entries of the todos of the report, running timers stopped now
+	entries := `SELECT te.todo_id, todos.body, te.started_at,
+		COALESCE(te.stopped_at, GREATEST(te.started_at, UTC_TIMESTAMP(6))) AS stopped_at
+		FROM time_entries te
+		JOIN todos ON todos.id = te.todo_id
+		WHERE ` + readableTodo + ` AND todos.deleted_at IS NULL`
+	args = append(args, userID)
+	if listID != "" {
+		entries += ` AND todos.list_id = ?`
+		args = append(args, listID)
+	}
+
+	// the part of each entry within each day
+	stmt := `WITH ` + cal + `,
+	entries AS (` + entries + `),
+	slices AS (
+		SELECT c.day, e.todo_id, e.body,
+		TIMESTAMPDIFF(SECOND, GREATEST(e.started_at, c.start_at), LEAST(e.stopped_at, c.end_at)) AS seconds
+		FROM calendar c
+		JOIN entries e ON e.started_at < c.end_at AND e.stopped_at > c.start_at
+	)`
+
+	report := &TimeReport{PerTodo: []TodoTime{}, PerDay: []DayTime{}}
+
+	rows, err := m.DB.Query(stmt+` SELECT c.day, COALESCE(SUM(s.seconds), 0) FROM calendar c
+	LEFT JOIN slices s ON s.day = c.day
+	GROUP BY c.day ORDER BY c.day`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d DayTime
+		var seconds int64
+		err = rows.Scan(&d.Date, &seconds)
+		if err != nil {
+			return nil, err
+		}
+		d.Duration = time.Duration(seconds) * time.Second
+		report.PerDay = append(report.PerDay, d)
+		report.Total += d.Duration
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = m.DB.Query(stmt+` SELECT todo_id, body, SUM(seconds) FROM slices
+	GROUP BY todo_id, body ORDER BY SUM(seconds) DESC, todo_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t TodoTime
+		var seconds int64
+		err = rows.Scan(&t.TodoID, &t.Body, &seconds)
+		if err != nil {
+			return nil, err
+		}
+		t.Duration = time.Duration(seconds) * time.Second
+		report.PerTodo = append(report.PerTodo, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// checkNoTimer returns ErrTimerRunning if userID has a running timer other
+// than the entry id. The running entries of userID are locked until the end
+// of tx, so that two timers cannot be started at once.
+func checkNoTimer(tx *sql.Tx, userID, id string) error {
+	var running int
+	stmt := `SELECT COUNT(*) FROM time_entries
+	WHERE user_id = ? AND stopped_at IS NULL AND id != ? FOR UPDATE`
+	err := tx.QueryRow(stmt, userID, id).Scan(&running)
+	if err != nil {
+		return err
+	}
+	if running > 0 {
+		return ErrTimerRunning
+	}
+	return nil
+}
+
+// stopMemberTimers stops the running timers of memberID on the todos of a
+// list they are leaving, their time on the list ends with their membership
+func stopMemberTimers(tx *sql.Tx, listID, memberID string) error {
+	stmt := `UPDATE time_entries SET stopped_at = GREATEST(started_at, UTC_TIMESTAMP(6))
+	WHERE user_id = ? AND stopped_at IS NULL
+	AND todo_id IN (SELECT id FROM todos WHERE list_id = ?)`
+
+	_, err := tx.Exec(stmt, memberID, listID)
+	if err != nil {
+		log.Printf("Error while stopping the timers of a member: %s", err)
+	}
+	return err
+}
+
+// runningTimerError turns a violation of the unique running timer of a
+// user into ErrTimerRunning, for the timers started concurrently
+func runningTimerError(err error) error {
+	var mySQLError *mysql.MySQLError
+	if errors.As(err, &mySQLError) {
+		if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "time_entries_uc_running") {
+			return ErrTimerRunning
+		}
+	}
+	return err
+}
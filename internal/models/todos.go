@@ -3,16 +3,125 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 )
 
 // define a todo type
 type Todo struct {
-	ID      string
-	Body    string
-	Status  bool
-	Created time.Time
+	ID     string
+	UserID string
+	ListID string
+	// ID of the parent todo, empty for top-level todos
+	ParentID string
+	Body     string
+	// long-form CommonMark notes, stored raw
+	Notes string
+	// true exactly when the todo is in the final state of its workflow
+	Status bool
+	// ID and name of the state of the todo in the workflow of the creator
+	// of its list, see State
+	StateID string
+	State   string
+	// time the todo was last completed, nil for open todos
+	CompletedAt *time.Time
+	Created     time.Time
+	// estimated effort, nil for todos without an estimate. It is given in
+	// EstimateUnit, the unit of the member who estimated it.
+	Estimate     *int
+	EstimateUnit string
+	EstimatedBy  string
+	// optional schedule of the todo
+	StartAt *time.Time
+	DueAt   *time.Time
+	// time until which the todo is snoozed, left out of the default lists;
+	// nil for todos which are not snoozed
+	HiddenUntil *time.Time
+	// one of the Priority constants
+	Priority int
+	// important todos belong to the upper half of the Eisenhower matrix
+	Important bool
+	// RFC 5545 recurrence rule of a recurring todo, empty otherwise
+	RRule string
+	// rank of the todo in the manual order of the user, see package rank
+	Position string
+	// ID and name of the member of the list the todo is assigned to, empty
+	// for unassigned todos
+	AssigneeID   string
+	AssigneeName string
+	// tags attached to the todo, sorted by name
+	Tags []*Tag
+	// progress of the direct subtasks of the todo
+	CompletedChildren int
+	TotalChildren     int
+	// number of comments on the todo
+	CommentCount int
+	// time the todo was moved to the trash, nil for live todos
+	DeletedAt *time.Time
+}
+
+// Todo priorities, in increasing order of urgency
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+// PriorityNames holds the name of each priority, indexed by its value
+var PriorityNames = []string{"none", "low", "medium", "high", "urgent"}
+
+// Urgent reports whether the priority of the todo makes it urgent
+func (t *Todo) Urgent() bool {
+	return t.Priority >= PriorityHigh
+}
+
+// todoColumns lists the columns read by scanTodo, in scan order
+const todoColumns = `id, user_id, list_id, parent_id, body, notes, status, created, start_at, due_at, priority, important, rrule, position, deleted_at,
+	assignee_id, (SELECT u.name FROM users u WHERE u.uuid = todos.assignee_id),
+	state_id, (SELECT ws.name FROM workflow_states ws WHERE ws.id = todos.state_id), completed_at,
+	estimate, estimate_unit, estimated_by, hidden_until,
+	(SELECT COUNT(*) FROM todos c WHERE c.parent_id = todos.id AND c.status = true AND c.deleted_at IS NULL),
+	(SELECT COUNT(*) FROM todos c WHERE c.parent_id = todos.id AND c.deleted_at IS NULL),
+	(SELECT COUNT(*) FROM comments cm WHERE cm.todo_id = todos.id)`
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTodo copies a row selected with todoColumns into a new Todo
+func scanTodo(row scanner) (*Todo, error) {
+	t := &Todo{}
+	var parentID, assigneeID, assigneeName, stateID, state, estimateUnit, estimatedBy sql.NullString
+	var startAt, dueAt, hiddenUntil, deletedAt, completedAt sql.NullTime
+	var estimate sql.NullInt64
+	// the arguments to Scan() must be pointers to the place you want to copy
+	// the data into, and the number of arguments must be exactly the same as
+	// the number of columns returned by the statement.
+	err := row.Scan(&t.ID, &t.UserID, &t.ListID, &parentID, &t.Body, &t.Notes, &t.Status, &t.Created, &startAt, &dueAt,
+		&t.Priority, &t.Important, &t.RRule, &t.Position, &deletedAt, &assigneeID, &assigneeName, &stateID, &state, &completedAt,
+		&estimate, &estimateUnit, &estimatedBy, &hiddenUntil, &t.CompletedChildren, &t.TotalChildren, &t.CommentCount)
+	if err != nil {
+		return nil, err
+	}
+	t.ParentID = parentID.String
+	t.AssigneeID = assigneeID.String
+	t.AssigneeName = assigneeName.String
+	t.StateID = stateID.String
+	t.State = state.String
+	t.DeletedAt = nullTimePtr(deletedAt)
+	t.CompletedAt = nullTimePtr(completedAt)
+	t.Estimate = nullIntPtr(estimate)
+	t.EstimateUnit = estimateUnit.String
+	t.EstimatedBy = estimatedBy.String
+	t.StartAt = nullTimePtr(startAt)
+	t.DueAt = nullTimePtr(dueAt)
+	t.HiddenUntil = nullTimePtr(hiddenUntil)
+	return t, nil
 }
 
 // define a todo model type which wraps a sql.DB connection pool
@@ -20,14 +129,22 @@ type TodoModel struct {
 	DB *sql.DB
 }
 
-// insert a new todo into the database
-func (m *TodoModel) Insert(newId, body string) (string, error) {
-	// use placeholder parameters instead of interpolating data in the SQL query
-	// as this is untrusted user input from a form
-	stmt := `INSERT INTO todos (id, body, created) 	VALUES(
-	?, ?, UTC_TIMESTAMP())`
+// insert a new todo into the database, t.ID, t.UserID (the owner)
+// and t.ListID must already be set
+func (m *TodoModel) Insert(t *Todo) (string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
 
-	_, err := m.DB.Exec(stmt, newId, body)
+	err = insertTodo(tx, t)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		return "", err
 	}
@@ -39,55 +156,149 @@ func (m *TodoModel) Insert(newId, body string) (string, error) {
 	// 	return 0, err
 	// }
 
-	// the id returned has the type int64, convert to an int type
-	return newId, nil
+	return t.ID, nil
 }
 
-// return a specific snippet based on its id
-func (m *TodoModel) Get(id int) (*Todo, error) {
+// insert several todos at once: either all of them are created or none
+// is. Parents must come before their subtasks. The todos end up at the top
+// of the manual order of their list, in the given order.
+func (m *TodoModel) InsertAll(todos []*Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	args := make([]any, 0, len(todos))
+	for _, t := range todos {
+		err = insertTodo(tx, t)
+		if err != nil {
+			return err
+		}
+		args = append(args, t.ID)
+	}
+
+	// each todo was put above the previous ones, hand their positions out
+	// again in the given order
+	stmt := `SELECT position FROM todos WHERE id IN (` + placeholders(len(todos)) + `) ORDER BY position`
+	rows, err := tx.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	positions := make([]string, 0, len(todos))
+	for rows.Next() {
+		var position string
+		err = rows.Scan(&position)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		positions = append(positions, position)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for i, t := range todos {
+		_, err = tx.Exec(`UPDATE todos SET position = ? WHERE id = ?`, positions[i], t.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// insertTodo inserts t in a transaction, at the top of the manual order
+// of its list. ErrNoRecord is returned unless the creator is an editor
+// of the list of the todo.
+func insertTodo(tx *sql.Tx, t *Todo) error {
+	position, err := firstPosition(tx, t.ListID)
+	if err != nil {
+		return err
+	}
+
+	// use placeholder parameters instead of interpolating data in the SQL query
+	// as this is untrusted user input from a form
+	stmt := `INSERT INTO todos (id, user_id, list_id, parent_id, body, notes, start_at, due_at, priority, important, rrule, position, assignee_id,
+	estimate, estimate_unit, estimated_by, created)
+	SELECT ?, ?, id, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP() FROM lists
+	WHERE id = ? AND ` + memberOf("lists.id", RoleEditor)
+
+	result, err := tx.Exec(stmt, t.ID, t.UserID, nullString(t.ParentID), t.Body, t.Notes, t.StartAt, t.DueAt, t.Priority, t.Important, t.RRule, position,
+		nullString(t.AssigneeID), t.Estimate, nullString(t.EstimateUnit), nullString(t.EstimatedBy), t.ListID, t.UserID)
+	if err != nil {
+		return err
+	}
+	err = checkRowsAffected(result)
+	if err != nil {
+		return err
+	}
+
+	// new todos start in the initial state
+	err = syncStates(tx, []string{t.ID})
+	if err != nil {
+		return err
+	}
+
+	return recordCreation(tx, t.UserID, t.ID)
+}
+
+// return a specific todo based on its id, as long as userID is a member
+// of its list
+func (m *TodoModel) Get(userID, id string) (*Todo, error) {
 	// Write the SQL statement we want to execute.
-	stmt := `SELECT id, body, created FROM todos
-	WHERE id = ?`
+	stmt := `SELECT ` + todoColumns + ` FROM todos
+	WHERE id = ? AND ` + readableTodo + ` AND deleted_at IS NULL`
 
 	// Use the QueryRow() method on the connection pool to execute our
 	// SQL statement, passing in the untrusted id variable as the value for
 	// the placeholder parameter. This returns a pointer to a sql.Row object
 	// which holds the result from the database.
-	row := m.DB.QueryRow(stmt, id)
+	row := m.DB.QueryRow(stmt, id, userID)
 
-	// Initialize a pointer to a new zeroed Snippet struct.
-	t := &Todo{}
-
-	// Use row.Scan() to copy the values from each field in sql.Row to the
-	// corresponding field in the Snippet struct. Notice that the arguments
-	// to row.Scan are *pointers* to the place you want to copy the data
-	// into, and the number of arguments must be exactly the same as the number of
-	// columns returned by your statement.
-	err := row.Scan(&t.ID, &t.Body, &t.Created)
+	// Use scanTodo() to copy the values from each field in sql.Row to a
+	// new Todo struct.
+	t, err := scanTodo(row)
 	if err != nil {
 		// If the query returns no rows, then row.Scan() will return a
 		// sql.ErrNoRows error. We use the errors.Is() function check for
 		// that error specifically, and return our own ErrNoRecord error
-		// instead (we'll create this in a moment).
+		// instead.
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
 		} else {
 			return nil, err
 		}
 	}
-	// If everything went OK then return the Snippet object.
+	err = m.loadTags([]*Todo{t})
+	if err != nil {
+		return nil, err
+	}
+	// If everything went OK then return the Todo object.
 	return t, nil
 }
 
-// return the all created todos
-func (m *TodoModel) All() ([]*Todo, error) {
+// return all todos of the lists userID is a member of, leaving out the
+// snoozed ones unless includeSnoozed is set
+func (m *TodoModel) All(userID string, includeSnoozed bool) ([]*Todo, error) {
 	// SQL statement we want to execute
-	stmt := `SELECT * FROM todos
-	ORDER BY created DESC`
+	stmt := `SELECT ` + todoColumns + ` FROM todos
+	WHERE ` + readableTodo + ` AND deleted_at IS NULL`
+	if !includeSnoozed {
+		stmt += ` AND ` + notSnoozed
+	}
+	stmt += ` ORDER BY position, id`
 
 	// Use the Query() method on the connection pool to execute the stmt
 	// this returns a sql.Rows resultset containing the result of our query
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.Query(stmt, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -107,14 +318,9 @@ func (m *TodoModel) All() ([]*Todo, error) {
 	// resultset automatically closes itself and frees-up the underlying
 	// database connection.
 	for rows.Next() {
-		// Create a pointer to a new zeroed Snippet struct.
-		t := &Todo{}
-		// Use rows.Scan() to copy the values from each field in the row to
-		// the new Snippet object that we created. Again, the arguments to
-		// row.Scan() must be pointers to the place you want to copy the data into, and
-		// the number of arguments must be exactly the same as the number of
-		// columns returned by your statement.
-		err = rows.Scan(&t.ID, &t.Body, &t.Created, &t.Status)
+		// Use scanTodo() to copy the values from each field in the row to
+		// a new Todo object.
+		t, err := scanTodo(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -128,54 +334,529 @@ func (m *TodoModel) All() ([]*Todo, error) {
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
-	// If everything went OK then return the Snippets slice.
+
+	err = m.loadTags(todos)
+	if err != nil {
+		return nil, err
+	}
+	// If everything went OK then return the Todos slice.
 	return todos, nil
 }
 
-// update
-func (m *TodoModel) Put(id, body string) error {
-	// SQL statement we want to execute
-	stmt := `UPDATE todos SET body = ? WHERE id = ?`
+// return one page of the todos of the lists userID is a member of, together with the cursor
+// of the next page (empty if this is the last one). Pages are read with keyset
+// pagination: rather than skipping rows with OFFSET, the query continues after
+// the sort key and ID of the last todo of the previous page.
+func (m *TodoModel) List(userID string, f TodoFilter) ([]*Todo, string, error) {
+	where := []string{readableTodo, "deleted_at IS NULL"}
+	args := []any{userID}
+
+	if f.ListID != "" {
+		where = append(where, "list_id = ?")
+		args = append(args, f.ListID)
+	}
+	if f.AssigneeID != "" {
+		where = append(where, "assignee_id = ?")
+		args = append(args, f.AssigneeID)
+	}
+	if f.TopLevel {
+		where = append(where, "parent_id IS NULL")
+	} else if f.ParentID != "" {
+		where = append(where, "parent_id = ?")
+		args = append(args, f.ParentID)
+	}
+	if !f.IncludeSnoozed {
+		where = append(where, notSnoozed)
+	}
+	if f.Status != nil {
+		where = append(where, "status = ?")
+		args = append(args, *f.Status)
+	}
+	if !f.CreatedAfter.IsZero() {
+		where = append(where, "created > ?")
+		args = append(args, f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		where = append(where, "created < ?")
+		args = append(args, f.CreatedBefore)
+	}
+	if !f.DueFrom.IsZero() {
+		where = append(where, "due_at >= ?")
+		args = append(args, f.DueFrom)
+	}
+	if !f.DueTo.IsZero() {
+		where = append(where, "due_at < ?")
+		args = append(args, f.DueTo)
+	}
+	if len(f.Tags) > 0 {
+		// todos carrying any of the tags, or all of them when the number
+		// of distinct matching tags equals the number of requested tags
+		tagged := `id IN (SELECT tt.todo_id FROM todo_tags tt
+		JOIN tags g ON g.id = tt.tag_id
+		WHERE g.user_id = ? AND g.name IN (` + placeholders(len(f.Tags)) + `)`
+		args = append(args, userID)
+		for _, name := range f.Tags {
+			args = append(args, name)
+		}
+		if f.TagsMatchAll {
+			tagged += ` GROUP BY tt.todo_id HAVING COUNT(DISTINCT g.id) = ?`
+			args = append(args, len(f.Tags))
+		}
+		where = append(where, tagged+")")
+	}
 
-	// Execute the statement with the provided id and body
-	_, err := m.DB.Exec(stmt, body, id)
+	// the column is taken from the safelist, never from user input
+	column := f.sortColumn()
+	direction, comparison := "ASC", ">"
+	if f.sortDescending() {
+		direction, comparison = "DESC", "<"
+	}
+
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Sort, f.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		// ties on the sort column are broken by the id
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, comparison))
+		args = append(args, c.value(), c.value(), c.ID)
+	}
+
+	// read one extra row to find out whether there is a next page
+	stmt := fmt.Sprintf(`SELECT %s FROM todos
+	WHERE %s
+	ORDER BY %s %s, id %s
+	LIMIT ?`, todoColumns, strings.Join(where, " AND "), column, direction, direction)
+	args = append(args, f.Limit+1)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	todos := []*Todo{}
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		todos = append(todos, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(todos) > f.Limit {
+		todos = todos[:f.Limit]
+		nextCursor = encodeCursor(f.Sort, todos[len(todos)-1])
+	}
+
+	err = m.loadTags(todos)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return todos, nextCursor, nil
+}
+
+// Matrix holds the open todos of a user sorted into the four quadrants of
+// the Eisenhower matrix: urgency comes from the priority, importance from
+// the important flag
+type Matrix struct {
+	DoFirst   []*Todo // urgent and important
+	Schedule  []*Todo // important but not urgent
+	Delegate  []*Todo // urgent but not important
+	Eliminate []*Todo // neither urgent nor important
+}
+
+// return the open todos of userID bucketed into the Eisenhower matrix,
+// most pressing first within each quadrant
+func (m *TodoModel) Matrix(userID string) (*Matrix, error) {
+	stmt := `SELECT ` + todoColumns + ` FROM todos
+	WHERE ` + readableTodo + ` AND status = false AND deleted_at IS NULL
+	ORDER BY priority DESC, due_at IS NULL, due_at, created`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matrix := &Matrix{
+		DoFirst:   []*Todo{},
+		Schedule:  []*Todo{},
+		Delegate:  []*Todo{},
+		Eliminate: []*Todo{},
+	}
+	todos := []*Todo{}
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, t)
+
+		switch {
+		case t.Urgent() && t.Important:
+			matrix.DoFirst = append(matrix.DoFirst, t)
+		case t.Important:
+			matrix.Schedule = append(matrix.Schedule, t)
+		case t.Urgent():
+			matrix.Delegate = append(matrix.Delegate, t)
+		default:
+			matrix.Eliminate = append(matrix.Eliminate, t)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = m.loadTags(todos)
+	if err != nil {
+		return nil, err
+	}
+
+	return matrix, nil
+}
+
+// update the editable fields of a todo, userID must be an editor of its list.
+// A changed estimate is recorded as given by userID, in t.EstimateUnit.
+func (m *TodoModel) Update(userID string, t *Todo) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	err = updateTodo(tx, userID, t)
 	if err != nil {
-		log.Printf("Error while attempting todo update %s", err)
 		return err
 	}
 
 	log.Printf("Updated successfully")
-	return nil
+	return tx.Commit()
 }
 
-// toggle status
-func (m *TodoModel) Toggle(id string) error {
+// update a todo and change its status at once, so that neither change is
+// saved if the other fails, see Update and SetStatus. If status completes
+// t, a recurring todo, next is its following occurrence as for
+// CompleteRecurring.
+func (m *TodoModel) UpdateStatus(userID string, t *Todo, status bool, next *Todo, cascade, force bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = updateTodo(tx, userID, t)
+	if err != nil {
+		return err
+	}
+
+	if status && !t.Status && t.RRule != "" {
+		err = completeRecurring(tx, userID, t.ID, next, cascade, force)
+	} else {
+		err = setStatus(tx, userID, t.ID, status, cascade, force)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// updateTodo saves the fields of t within tx, see Update
+func updateTodo(tx *sql.Tx, userID string, t *Todo) error {
+	// keep the previous values in the history of the todo
+	rr, err := trackRevisions(tx, userID, ActionUpdate, t.ID)
+	if err != nil {
+		return err
+	}
+
+	// the estimate is assigned last, so that the columns describing it
+	// compare its previous value
+	var estimatedBy sql.NullString
+	if t.Estimate != nil {
+		estimatedBy = nullString(userID)
+	}
+
 	// SQL statement we want to execute
-	stmt := `UPDATE todos SET status = !status WHERE id = ?`
+	stmt := `UPDATE todos SET body = ?, notes = ?, start_at = ?, due_at = ?, priority = ?, important = ?, rrule = ?,
+	estimate_unit = IF(estimate <=> ?, estimate_unit, ?), estimated_by = IF(estimate <=> ?, estimated_by, ?), estimate = ?
+	WHERE id = ? AND ` + writableTodo + ` AND deleted_at IS NULL`
+
+	// Execute the statement with the provided id and fields
+	result, err := tx.Exec(stmt, t.Body, t.Notes, t.StartAt, t.DueAt, t.Priority, t.Important, t.RRule,
+		t.Estimate, nullString(t.EstimateUnit), t.Estimate, estimatedBy, t.Estimate, t.ID, userID)
+	if err != nil {
+		log.Printf("Error while attempting todo update %s", err)
+		return err
+	}
+
+	err = checkRowsAffected(result)
+	if err != nil {
+		return err
+	}
 
-	// Execute the statement with the provided id and body
-	_, err := m.DB.Exec(stmt, id)
+	return rr.record()
+}
+
+// move a todo and its subtasks to another list, userID must be an editor
+// of both lists. Todos assigned to users who are not members of the new
+// list are unassigned.
+func (m *TodoModel) SetList(userID, id, listID string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	ids, err := descendantIDs(tx, id)
+	if err != nil {
+		return err
+	}
+	rr, err := trackRevisions(tx, userID, ActionMove, append(ids, id)...)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE todos SET list_id = ?
+	WHERE id = ? AND ` + writableTodo + ` AND deleted_at IS NULL
+	AND ` + memberOf("?", RoleEditor)
+
+	result, err := tx.Exec(stmt, listID, id, userID, listID, userID)
+	if err != nil {
+		log.Printf("Error while moving a todo to another list %s", err)
+		return err
+	}
+	err = checkRowsAffected(result)
+	if err != nil {
+		return err
+	}
+
+	// subtasks always live in the list of their parent
+	err = setDescendants(tx, id, "list_id", listID)
+	if err != nil {
+		return err
+	}
+
+	err = unassignNonMembers(tx, listID, append(ids, id))
+	if err != nil {
+		return err
+	}
+
+	err = syncStates(tx, append(ids, id))
+	if err != nil {
+		return err
+	}
+
+	err = rr.record()
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// set the status of a todo to an explicit value, userID must be an editor
+// of its list or the assignee of the todo. If cascade is set, the status is
+// applied to all of its subtasks as well. Done todos move to the final
+// state of their workflow, reopened ones back to the initial state. Unless
+// force is set, ErrBlocked is returned if a todo to complete waits for an
+// open todo.
+func (m *TodoModel) SetStatus(userID, id string, status, cascade, force bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = setStatus(tx, userID, id, status, cascade, force)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setStatus sets the status of a todo within tx, see SetStatus
+func setStatus(tx *sql.Tx, userID, id string, status, cascade, force bool) error {
+	ids := []string{id}
+	if cascade {
+		descendants, err := descendantIDs(tx, id)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, descendants...)
+	}
+	if status && !force {
+		err := checkBlockers(tx, ids)
+		if err != nil {
+			return err
+		}
+	}
+	rr, err := trackRevisions(tx, userID, ActionStatus, ids...)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE todos SET status = ? WHERE id = ? AND ` + completableTodo + ` AND deleted_at IS NULL`
+
+	result, err := tx.Exec(stmt, status, id, userID)
+	if err != nil {
+		log.Printf("Error while attempting todo status update %s", err)
+		return err
+	}
+	err = checkRowsAffected(result)
+	if err != nil {
+		return err
+	}
+
+	if cascade {
+		err = setDescendants(tx, id, "status", status)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = syncStates(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	err = stampCompletion(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	return rr.record()
+}
+
+// toggle status, cascading the new status to the subtasks if requested.
+// The status is read and written in one transaction, so that concurrent
+// toggles take turns. Completing a blocked todo requires force.
+func (m *TodoModel) Toggle(userID, id string, cascade, force bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status bool
+	stmt := `SELECT status FROM todos WHERE id = ? AND ` + completableTodo + ` AND deleted_at IS NULL FOR UPDATE`
+	err = tx.QueryRow(stmt, id, userID).Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	err = setStatus(tx, userID, id, !status, cascade, force)
 	if err != nil {
 		log.Printf("Error while attempting todo status toggle %s", err)
 		return err
 	}
 
 	log.Printf("Status toggled successfully")
-	return nil
+	return tx.Commit()
 }
 
-// delete
-func (m *TodoModel) Delete(id string) error {
-	// Execute the statement with the provided id
-	stmt := `DELETE FROM todos WHERE id = ?`
+// loadTags fills in the tags of the given todos with a single query
+func (m *TodoModel) loadTags(todos []*Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]*Todo, len(todos))
+	args := make([]any, 0, len(todos))
+	for _, t := range todos {
+		t.Tags = []*Tag{}
+		byID[t.ID] = t
+		args = append(args, t.ID)
+	}
 
-	result, err := m.DB.Exec(stmt, id)
+	stmt := `SELECT tt.todo_id, g.id, g.user_id, g.name, g.color, g.created
+	FROM todo_tags tt
+	JOIN tags g ON g.id = tt.tag_id
+	WHERE tt.todo_id IN (` + placeholders(len(todos)) + `)
+	ORDER BY g.name`
+
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
-		log.Printf("Error while deleting a todo: %s", err)
 		return err
 	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoID string
+		g := &Tag{}
+		err = rows.Scan(&todoID, &g.ID, &g.UserID, &g.Name, &g.Color, &g.Created)
+		if err != nil {
+			return err
+		}
+		if t, ok := byID[todoID]; ok {
+			t.Tags = append(t.Tags, g)
+		}
+	}
+
+	return rows.Err()
+}
+
+// placeholders returns n comma separated "?" placeholders for an IN clause
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
 
-	// Check if the record was actually deleted
+// stampCompletion records when the todos ids were completed, after their
+// status changed: done todos keep the time they were marked as done,
+// reopened todos lose it
+func stampCompletion(tx *sql.Tx, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	stmt := `UPDATE todos SET completed_at = IF(status, COALESCE(completed_at, UTC_TIMESTAMP()), NULL)
+	WHERE id IN (` + placeholders(len(ids)) + `)`
+
+	_, err := tx.Exec(stmt, args...)
+	return err
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullIntPtr converts a nullable column into an optional int
+func nullIntPtr(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	i := int(n.Int64)
+	return &i
+}
+
+// nullTimePtr converts a nullable column into an optional time
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// checkRowsAffected returns ErrNoRecord if a statement did not match any row.
+// The connection is opened with clientFoundRows=true, so an UPDATE that matches
+// a row without changing it still reports that row as affected.
+func checkRowsAffected(result sql.Result) error {
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		log.Printf("Error while checking rows affected: %s", err)
@@ -183,11 +864,7 @@ func (m *TodoModel) Delete(id string) error {
 	}
 
 	if rowsAffected == 0 {
-		// No rows were affected, meaning the ID might not exist
-		log.Printf("No rows affected, possible non-existent ID: %s", id)
-		return nil
+		return ErrNoRecord
 	}
-
-	log.Printf("Deleted successfully")
 	return nil
 }
@@ -0,0 +1,312 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// move a todo and its live subtasks to the trash, userID must be an editor
+// of its list. Trashed todos are left out of every other query, until they
+// are restored or purged.
+func (m *TodoModel) Delete(userID, id string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	// the subtasks share the time of the todo, so that
+	// they can be restored together
+	now := time.Now().UTC().Truncate(time.Second)
+
+	ids, err := descendantIDs(tx, id)
+	if err != nil {
+		return err
+	}
+	rr, err := trackRevisions(tx, userID, ActionDelete, append(ids, id)...)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE todos SET deleted_at = ? WHERE id = ? AND ` + writableTodo + ` AND deleted_at IS NULL`
+
+	result, err := tx.Exec(stmt, now, id, userID)
+	if err != nil {
+		log.Printf("Error while deleting a todo: %s", err)
+		return err
+	}
+
+	// Check if the record was actually deleted
+	err = checkRowsAffected(result)
+	if err != nil {
+		// No rows were affected, meaning the ID does not exist,
+		// is out of reach of the user or is already in the trash
+		log.Printf("No rows affected, possible non-existent ID: %s", id)
+		return err
+	}
+
+	err = setDescendants(tx, id, "deleted_at", now)
+	if err != nil {
+		return err
+	}
+
+	err = rr.record()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Moved to the trash successfully")
+	return tx.Commit()
+}
+
+// return the trashed todos of the lists userID is a member of, most
+// recently deleted first. Subtasks
+// trashed together with their parent are left out, they come back with it.
+func (m *TodoModel) Trash(userID string) ([]*Todo, error) {
+	stmt := `SELECT ` + todoColumns + ` FROM todos
+	WHERE ` + readableTodo + ` AND deleted_at IS NOT NULL
+	AND NOT EXISTS(SELECT true FROM todos p WHERE p.id = todos.parent_id AND p.deleted_at = todos.deleted_at)
+	ORDER BY deleted_at DESC, id`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []*Todo{}
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = m.loadTags(todos)
+	if err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// return a trashed todo of a list userID is a member of
+func (m *TodoModel) GetDeleted(userID, id string) (*Todo, error) {
+	stmt := `SELECT ` + todoColumns + ` FROM todos
+	WHERE id = ? AND ` + readableTodo + ` AND deleted_at IS NOT NULL`
+
+	t, err := scanTodo(m.DB.QueryRow(stmt, id, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	err = m.loadTags([]*Todo{t})
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// take a todo out of the trash, together with the subtasks trashed with it.
+// userID must be an editor of its list. If its parent is still in the
+// trash, the todo becomes a top-level todo.
+func (m *TodoModel) Restore(userID, id string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	var parentID sql.NullString
+	stmt := `SELECT deleted_at, parent_id FROM todos WHERE id = ? AND ` + writableTodo + ` AND deleted_at IS NOT NULL`
+	err = tx.QueryRow(stmt, id, userID).Scan(&deletedAt, &parentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	ids, err := trashedDescendantIDs(tx, id, deletedAt)
+	if err != nil {
+		return err
+	}
+	ids = append(ids, id)
+
+	rr, err := trackRevisions(tx, userID, ActionRestore, ids...)
+	if err != nil {
+		return err
+	}
+
+	args := []any{}
+	for _, restoredID := range ids {
+		args = append(args, restoredID)
+	}
+	stmt = `UPDATE todos SET deleted_at = NULL WHERE id IN (` + placeholders(len(ids)) + `)`
+	_, err = tx.Exec(stmt, args...)
+	if err != nil {
+		log.Printf("Error while restoring a todo: %s", err)
+		return err
+	}
+
+	if parentID.Valid {
+		var parentLive bool
+		stmt = `SELECT EXISTS(SELECT true FROM todos WHERE id = ? AND deleted_at IS NULL)`
+		err = tx.QueryRow(stmt, parentID.String).Scan(&parentLive)
+		if err != nil {
+			return err
+		}
+		if !parentLive {
+			_, err = tx.Exec(`UPDATE todos SET parent_id = NULL WHERE id = ?`, id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = rr.record()
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// trashedDescendantIDs returns the IDs of the subtasks of a todo which were
+// trashed at the same time as the todo, at any depth
+func trashedDescendantIDs(q querier, id string, deletedAt time.Time) ([]string, error) {
+	stmt := `WITH RECURSIVE subtree (id) AS (
+		SELECT id FROM todos WHERE parent_id = ? AND deleted_at = ?
+		UNION ALL
+		SELECT c.id FROM todos c JOIN subtree s ON c.parent_id = s.id
+		WHERE c.deleted_at = ?
+	)
+	SELECT id FROM subtree`
+
+	rows, err := q.Query(stmt, id, deletedAt, deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var childID string
+		err = rows.Scan(&childID)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, childID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// permanently delete a trashed todo of a list userID is an editor of,
+// together with its subtasks
+func (m *TodoModel) Purge(userID, id string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	n, err := deleteSubtrees(tx, `id = ? AND `+writableTodo+` AND deleted_at IS NOT NULL`, id, userID)
+	if err != nil {
+		log.Printf("Error while purging a todo: %s", err)
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return tx.Commit()
+}
+
+// permanently delete the todos of all users trashed before t, and return
+// the number of todos deleted, subtasks included
+func (m *TodoModel) PurgeDeletedBefore(t time.Time) (int64, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	n, err := deleteSubtrees(tx, `deleted_at < ?`, t.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
+
+// deleteSubtrees deletes the todos matching where with all of their
+// subtasks, the deepest first, and returns the number of todos deleted.
+// The parent_id foreign key is left with nothing to cascade to, as InnoDB
+// stops cascading after 15 levels.
+func deleteSubtrees(q querier, where string, args ...any) (int64, error) {
+	stmt := `WITH RECURSIVE subtree (id, depth) AS (
+		SELECT id, 0 FROM todos WHERE ` + where + `
+		UNION ALL
+		SELECT c.id, s.depth + 1 FROM todos c JOIN subtree s ON c.parent_id = s.id
+	)
+	SELECT id, MAX(depth) AS depth FROM subtree GROUP BY id ORDER BY depth DESC`
+
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	// levels[i] holds the IDs found at the i-th depth, the deepest first
+	levels := [][]any{}
+	depth := -1
+	for rows.Next() {
+		var id string
+		var d int
+		err = rows.Scan(&id, &d)
+		if err != nil {
+			return 0, err
+		}
+		if d != depth {
+			levels = append(levels, []any{})
+			depth = d
+		}
+		levels[len(levels)-1] = append(levels[len(levels)-1], id)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	var n int64
+	for _, ids := range levels {
+		stmt = `DELETE FROM todos WHERE id IN (` + placeholders(len(ids)) + `)`
+		result, err := q.Exec(stmt, ids...)
+		if err != nil {
+			return 0, err
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		n += deleted
+	}
+
+	return n, nil
+}
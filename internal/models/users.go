@@ -18,15 +18,32 @@ type User struct {
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	// IANA time zone used to compute "today" and "this week" for the user
+	Timezone string
+	// one of the Estimate constants, the unit of the estimates the user
+	// gives todos
+	EstimateUnit string
 }
 
+// DefaultTimezone is used for users that did not pick a time zone
+const DefaultTimezone = "UTC"
+
+// Units of the estimates of todos, users estimate in minutes by default
+const (
+	EstimateMinutes = "minutes"
+	EstimatePoints  = "points"
+)
+
+// EstimateUnits holds the units a user can estimate todos in
+var EstimateUnits = []string{EstimateMinutes, EstimatePoints}
+
 // define UserModel type which wraps a database connection pool
 type UserModel struct {
 	DB *sql.DB
 }
 
 // add a new record to the users table
-func (m *UserModel) Insert(newId, name, email, password string) error {
+func (m *UserModel) Insert(newId, name, email, password, timezone string) error {
 	fmt.Println("Attempting to insert new user into database...")
 
 	// create a bcrypt hash of the plain-text password
@@ -34,11 +51,11 @@ func (m *UserModel) Insert(newId, name, email, password string) error {
 	if err != nil {
 		return err
 	}
-	stmt := `INSERT INTO users (uuid, name, email, hashed_password, created)
-VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+	stmt := `INSERT INTO users (uuid, name, email, hashed_password, timezone, created)
+VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP())`
 
 	// insert with Exec()
-	_, err = m.DB.Exec(stmt, newId, name, email, string(hashedPassword))
+	_, err = m.DB.Exec(stmt, newId, name, email, string(hashedPassword), timezone)
 	if err != nil {
 		// If this returns an error, we use the errors.As() function to check
 		// whether the error has the type *mysql.MySQLError. If it does, the
@@ -100,3 +117,50 @@ func (m *UserModel) Exists(uuid string) (bool, error) {
 
 	return exists, err
 }
+
+// Get method returns the user with a specific ID, without the password hash.
+func (m *UserModel) Get(uuid string) (*User, error) {
+	u := &User{}
+	stmt := "SELECT uuid, name, email, timezone, estimate_unit, created FROM users WHERE uuid = ?"
+
+	err := m.DB.QueryRow(stmt, uuid).Scan(&u.Uuid, &u.Name, &u.Email, &u.Timezone, &u.EstimateUnit, &u.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return u, nil
+}
+
+// GetByEmail method returns the user registered with an email address,
+// without the password hash.
+func (m *UserModel) GetByEmail(email string) (*User, error) {
+	u := &User{}
+	stmt := "SELECT uuid, name, email, timezone, estimate_unit, created FROM users WHERE email = ?"
+
+	err := m.DB.QueryRow(stmt, email).Scan(&u.Uuid, &u.Name, &u.Email, &u.Timezone, &u.EstimateUnit, &u.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return u, nil
+}
+
+// Update method changes the profile fields of a user.
+func (m *UserModel) Update(u *User) error {
+	stmt := "UPDATE users SET name = ?, timezone = ?, estimate_unit = ? WHERE uuid = ?"
+
+	result, err := m.DB.Exec(stmt, u.Name, u.Timezone, u.EstimateUnit, u.Uuid)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
@@ -0,0 +1,127 @@
+// Package rank implements fractional indexing: ranks are strings which sort
+// in the order of their items, and a new rank can always be found between
+// two others, so that moving an item only changes the rank of that item.
+//
+// Ranks use the base 62 digits 0-9, A-Z and a-z, which sort the same way as
+// bytes, and never end with the smallest digit, so that there is always
+// room before any rank.
+package rank
+
+import (
+	"errors"
+	"strings"
+)
+
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const base = len(digits)
+
+// ErrInvalidRank is returned for ranks which are not made of the rank
+// digits, end with the smallest digit, or are not in order
+var ErrInvalidRank = errors.New("rank: invalid rank")
+
+// Valid reports whether s is a rank
+func Valid(s string) bool {
+	if s == "" || s[len(s)-1] == digits[0] {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(digits, s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Between returns a rank sorting strictly after a and before b. An empty a
+// means there is no lower bound, an empty b that there is no upper bound.
+// The result is as short as possible, but grows by about one digit every
+// few inserts at the same place, see Spread.
+func Between(a, b string) (string, error) {
+	if a != "" && !Valid(a) || b != "" && !Valid(b) {
+		return "", ErrInvalidRank
+	}
+	if a != "" && b != "" && a >= b {
+		return "", ErrInvalidRank
+	}
+	return midpoint(a, b), nil
+}
+
+// midpoint returns the rank between a and b, b being empty for no
+// upper bound. Both are valid and a < b.
+func midpoint(a, b string) string {
+	if b != "" {
+		// keep the common prefix, a being padded with zeros
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(suffix(a, n), b[n:])
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(digits, a[0])
+	}
+	digitB := base
+	if b != "" {
+		digitB = strings.IndexByte(digits, b[0])
+	}
+
+	// a digit fits between the first digits of a and b
+	if digitB-digitA > 1 {
+		return string(digits[(digitA+digitB+1)/2])
+	}
+	// the first digit of b alone sorts before b
+	if len(b) > 1 {
+		return b[:1]
+	}
+	// otherwise keep the first digit of a and go one level deeper
+	return string(digits[digitA]) + midpoint(suffix(a, 1), "")
+}
+
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return digits[0]
+}
+
+func suffix(s string, i int) string {
+	if i < len(s) {
+		return s[i:]
+	}
+	return ""
+}
+
+// Spread returns n ranks of the same, minimal, length evenly spread over the
+// whole range, leaving room for new ranks between and around all of them.
+// It is used to rebalance ranks which have grown long.
+func Spread(n int) []string {
+	// find a length with at least 4 free values between consecutive ranks
+	length, capacity := 1, uint64(base)
+	for capacity < 4*uint64(n+1) {
+		length++
+		capacity *= uint64(base)
+	}
+	step := capacity / uint64(n+1)
+
+	ranks := make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		ranks = append(ranks, encode(uint64(i)*step, length))
+	}
+	return ranks
+}
+
+// encode writes v with length digits, without trailing zeros, which keeps
+// the order of values encoded with the same length
+func encode(v uint64, length int) string {
+	b := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		b[i] = digits[v%uint64(base)]
+		v /= uint64(base)
+	}
+	return strings.TrimRight(string(b), digits[:1])
+}
@@ -0,0 +1,164 @@
+package rank
+
+import (
+	"errors"
+	"testing"
+)
+
+// checkBetween fails unless got is a rank sorting strictly between a and
+// b, empty bounds being open
+func checkBetween(t *testing.T, a, b, got string) {
+	t.Helper()
+	if !Valid(got) {
+		t.Fatalf("Between(%q, %q) = %q, which is not a valid rank", a, b, got)
+	}
+	if a != "" && got <= a || b != "" && got >= b {
+		t.Fatalf("Between(%q, %q) = %q, which is out of order", a, b, got)
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		rank string
+		want bool
+	}{
+		{rank: "V", want: true},
+		{rank: "a0V", want: true},
+		{rank: "zzz", want: true},
+		{rank: "", want: false},
+		{rank: "0", want: false},
+		{rank: "a0", want: false},
+		{rank: "a-b", want: false},
+		{rank: "é", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rank, func(t *testing.T) {
+			if got := Valid(tt.rank); got != tt.want {
+				t.Errorf("Valid(%q) = %t; want %t", tt.rank, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{name: "Empty range", a: "", b: ""},
+		{name: "Before", a: "", b: "V"},
+		{name: "After", a: "V", b: ""},
+		{name: "Before the smallest digit", a: "", b: "1"},
+		{name: "After the largest digit", a: "z", b: ""},
+		{name: "Far apart", a: "1", b: "z"},
+		{name: "Adjacent digits", a: "A", b: "B"},
+		{name: "Prefix", a: "a", b: "a1"},
+		{name: "Common prefix", a: "aV1", b: "aV2"},
+		{name: "Different lengths", a: "a01", b: "b"},
+		{name: "Longer lower bound", a: "Azzz", b: "B"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Between(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("Between(%q, %q): %s", tt.a, tt.b, err)
+			}
+			checkBetween(t, tt.a, tt.b, got)
+		})
+	}
+}
+
+func TestBetweenInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{name: "Trailing zero", a: "a0", b: ""},
+		{name: "Bad digit", a: "", b: "a!"},
+		{name: "Equal", a: "a", b: "a"},
+		{name: "Reversed", a: "b", b: "a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Between(tt.a, tt.b)
+			if !errors.Is(err, ErrInvalidRank) {
+				t.Errorf("Between(%q, %q): got error %v; want %v", tt.a, tt.b, err, ErrInvalidRank)
+			}
+		})
+	}
+}
+
+func TestBetweenRepeated(t *testing.T) {
+	tests := []struct {
+		name string
+		// next returns the bounds of the next insert, given the
+		// previous bounds and the rank inserted between them
+		next func(a, b, got string) (string, string)
+	}{
+		{name: "At the start", next: func(a, b, got string) (string, string) { return "", got }},
+		{name: "At the end", next: func(a, b, got string) (string, string) { return got, "" }},
+		{name: "Right after the same rank", next: func(a, b, got string) (string, string) { return a, got }},
+		{name: "Right before the same rank", next: func(a, b, got string) (string, string) { return got, b }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := "V", "W"
+			for i := 0; i < 200; i++ {
+				got, err := Between(a, b)
+				if err != nil {
+					t.Fatalf("insert %d: Between(%q, %q): %s", i, a, b, err)
+				}
+				checkBetween(t, a, b, got)
+				a, b = tt.next(a, b, got)
+			}
+			// ranks grow by about one digit every few inserts
+			if len(a) > 100 || len(b) > 100 {
+				t.Errorf("ranks grew to %d and %d digits after 200 inserts", len(a), len(b))
+			}
+		})
+	}
+}
+
+func TestSpread(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 15, 61, 62, 1000, 100000} {
+		ranks := Spread(n)
+		if len(ranks) != n {
+			t.Fatalf("Spread(%d) returned %d ranks", n, len(ranks))
+		}
+		for i, r := range ranks {
+			if !Valid(r) {
+				t.Fatalf("Spread(%d)[%d] = %q, which is not a valid rank", n, i, r)
+			}
+			if i > 0 && r <= ranks[i-1] {
+				t.Fatalf("Spread(%d) is out of order at %d: %q after %q", n, i, r, ranks[i-1])
+			}
+		}
+		if n == 0 {
+			continue
+		}
+
+		length := 0
+		for _, r := range ranks {
+			length = max(length, len(r))
+		}
+
+		// there is room between and around all of them, one digit
+		// longer at most
+		bounds := append([]string{""}, ranks...)
+		bounds = append(bounds, "")
+		for i := 0; i+1 < len(bounds); i++ {
+			got, err := Between(bounds[i], bounds[i+1])
+			if err != nil {
+				t.Fatalf("Spread(%d): Between(%q, %q): %s", n, bounds[i], bounds[i+1], err)
+			}
+			checkBetween(t, bounds[i], bounds[i+1], got)
+			if len(got) > length+1 {
+				t.Errorf("Spread(%d): Between(%q, %q) = %q, which is longer than %d digits", n, bounds[i], bounds[i+1], got, length+1)
+			}
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package recurrence
+
+import "time"
+
+// maximum number of periods (days, weeks, months or years) searched by Next,
+// enough for rules such as "every February 29th"
+const maxPeriods = 4000
+
+// Next returns the first occurrence of the rule strictly after current, the
+// date of the current occurrence. Periods are counted from current, which
+// is treated as the start of the series, and the occurrence keeps its time
+// of day. Days are those of the location of current. The second result is
+// false once the series is over.
+func (r *Rule) Next(current time.Time) (time.Time, bool) {
+	// the current occurrence was the last one
+	if r.Count == 1 {
+		return time.Time{}, false
+	}
+
+	// dates are compared as UTC midnights, so that days
+	// always last 24 hours whatever the time zone
+	anchor := date(current.Year(), current.Month(), current.Day())
+
+	for k := 0; k < maxPeriods; k++ {
+		days := r.periodDays(anchor, k)
+		if days == nil {
+			break
+		}
+		for _, day := range days {
+			next := time.Date(day.Year(), day.Month(), day.Day(),
+				current.Hour(), current.Minute(), current.Second(), 0, current.Location())
+			if !next.After(current) {
+				continue
+			}
+			if !r.Until.IsZero() && next.After(r.Until) {
+				return time.Time{}, false
+			}
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// periodDays returns the days of the k-th period after the one holding
+// anchor which match the rule, in order. It returns nil once past year 9999.
+func (r *Rule) periodDays(anchor time.Time, k int) []time.Time {
+	step := k * r.Interval
+	days := []time.Time{}
+
+	switch r.Freq {
+	case Daily:
+		day := anchor.AddDate(0, 0, step)
+		if day.Year() > 9999 {
+			return nil
+		}
+		if r.inMonths(day.Month()) && r.matchesMonthDay(day) && r.matchesWeekday(day) {
+			days = append(days, day)
+		}
+
+	case Weekly:
+		start := anchor.AddDate(0, 0, -((int(anchor.Weekday())-int(r.WeekStart)+7)%7)+7*step)
+		if start.Year() > 9999 {
+			return nil
+		}
+		for i := 0; i < 7; i++ {
+			day := start.AddDate(0, 0, i)
+			matches := day.Weekday() == anchor.Weekday()
+			if len(r.ByDay) > 0 {
+				matches = r.matchesWeekday(day)
+			}
+			if matches && r.inMonths(day.Month()) {
+				days = append(days, day)
+			}
+		}
+
+	case Monthly:
+		first := date(anchor.Year(), anchor.Month()+time.Month(step), 1)
+		if first.Year() > 9999 {
+			return nil
+		}
+		if r.inMonths(first.Month()) {
+			days = r.monthDays(first, anchor.Day())
+		}
+
+	case Yearly:
+		year := anchor.Year() + step
+		if year > 9999 {
+			return nil
+		}
+		switch {
+		case len(r.ByMonth) > 0:
+			for _, m := range sortedMonths(r.ByMonth) {
+				days = append(days, r.monthDays(date(year, m, 1), anchor.Day())...)
+			}
+		case len(r.ByDay) > 0 && len(r.ByMonthDay) == 0:
+			// numbered weekdays count within the whole year
+			first, last := date(year, time.January, 1), date(year, time.December, 31)
+			for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+				if r.matchesByDay(day, first, last) {
+					days = append(days, day)
+				}
+			}
+		case len(r.ByMonthDay) > 0:
+			for m := time.January; m <= time.December; m++ {
+				days = append(days, r.monthDays(date(year, m, 1), anchor.Day())...)
+			}
+		default:
+			days = r.monthDays(date(year, anchor.Month(), 1), anchor.Day())
+		}
+	}
+
+	return days
+}
+
+// monthDays returns the days of the month starting on first which match
+// BYMONTHDAY and BYDAY, or the anchor day of the month if neither is set.
+// Months too short for the anchor day are skipped, as RFC 5545 requires.
+func (r *Rule) monthDays(first time.Time, anchorDay int) []time.Time {
+	last := first.AddDate(0, 1, -1)
+	days := []time.Time{}
+
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		var matches bool
+		switch {
+		case len(r.ByMonthDay) > 0 && len(r.ByDay) > 0:
+			matches = r.matchesMonthDay(day) && r.matchesByDay(day, first, last)
+		case len(r.ByMonthDay) > 0:
+			matches = r.matchesMonthDay(day)
+		case len(r.ByDay) > 0:
+			matches = r.matchesByDay(day, first, last)
+		default:
+			matches = day.Day() == anchorDay
+		}
+		if matches {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// inMonths reports whether m is allowed by BYMONTH
+func (r *Rule) inMonths(m time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, month := range r.ByMonth {
+		if month == m {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMonthDay reports whether day is allowed by BYMONTHDAY, negative
+// values count from the end of the month
+func (r *Rule) matchesMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := date(day.Year(), day.Month()+1, 0).Day()
+	for _, n := range r.ByMonthDay {
+		if n == day.Day() || n < 0 && daysInMonth+n+1 == day.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWeekday reports whether the weekday of day is allowed by BYDAY,
+// ignoring numbers
+func (r *Rule) matchesWeekday(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesByDay reports whether day is allowed by BYDAY, numbered weekdays
+// being counted within [first, last]
+func (r *Rule) matchesByDay(day, first, last time.Time) bool {
+	for _, wd := range r.ByDay {
+		if wd.Day != day.Weekday() {
+			continue
+		}
+		switch {
+		case wd.N == 0:
+			return true
+		case wd.N > 0 && daysBetween(first, day)/7+1 == wd.N:
+			return true
+		case wd.N < 0 && daysBetween(day, last)/7+1 == -wd.N:
+			return true
+		}
+	}
+	return false
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// sortedMonths returns the months in calendar order
+func sortedMonths(months []time.Month) []time.Month {
+	sorted := []time.Month{}
+	for m := time.January; m <= time.December; m++ {
+		for _, month := range months {
+			if month == m {
+				sorted = append(sorted, m)
+				break
+			}
+		}
+	}
+	return sorted
+}
@@ -0,0 +1,156 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+)
+
+// mustParse parses a rule known to be valid
+func mustParse(t *testing.T, s string) *Rule {
+	t.Helper()
+	r, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q): %s", s, err)
+	}
+	return r
+}
+
+func TestNext(t *testing.T) {
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 9, 30, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		current time.Time
+		want    time.Time
+	}{
+		{name: "Daily", rule: "FREQ=DAILY", current: at(2024, 12, 31), want: at(2025, 1, 1)},
+		{name: "Every other week", rule: "FREQ=WEEKLY;INTERVAL=2", current: at(2024, 5, 1), want: at(2024, 5, 15)},
+		{name: "Weekdays over a weekend", rule: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", current: at(2024, 5, 3), want: at(2024, 5, 6)},
+		{name: "Weekdays within a week", rule: "FREQ=WEEKLY;BYDAY=MO,WE,FR", current: at(2024, 5, 6), want: at(2024, 5, 8)},
+		{name: "First Monday", rule: "FREQ=MONTHLY;BYDAY=1MO", current: at(2024, 5, 6), want: at(2024, 6, 3)},
+		{name: "Last Friday", rule: "FREQ=MONTHLY;BYDAY=-1FR", current: at(2024, 5, 31), want: at(2024, 6, 28)},
+		{name: "Month days", rule: "FREQ=MONTHLY;BYMONTHDAY=1,15", current: at(2024, 5, 15), want: at(2024, 6, 1)},
+		{name: "Last day of February", rule: "FREQ=MONTHLY;BYMONTHDAY=-1", current: at(2024, 1, 31), want: at(2024, 2, 29)},
+		{name: "Short months skipped", rule: "FREQ=MONTHLY", current: at(2024, 1, 31), want: at(2024, 3, 31)},
+		{name: "30-day months skipped", rule: "FREQ=MONTHLY", current: at(2024, 3, 31), want: at(2024, 5, 31)},
+		{name: "Leap day", rule: "FREQ=YEARLY", current: at(2024, 2, 29), want: at(2028, 2, 29)},
+		{name: "Yearly by month", rule: "FREQ=YEARLY;BYMONTH=3,9", current: at(2024, 9, 10), want: at(2025, 3, 10)},
+		{name: "Until the same day", rule: "FREQ=DAILY;UNTIL=20240502", current: at(2024, 5, 1), want: at(2024, 5, 2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mustParse(t, tt.rule).Next(tt.current)
+			if !ok {
+				t.Fatalf("got no occurrence; want %s", tt.want)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextOver(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		current time.Time
+	}{
+		{name: "Last of the count", rule: "FREQ=DAILY;COUNT=1", current: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)},
+		{name: "Past the until date", rule: "FREQ=DAILY;UNTIL=20240502", current: time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)},
+		{name: "Past the until time", rule: "FREQ=WEEKLY;UNTIL=20240507T120000Z", current: time.Date(2024, 4, 30, 13, 0, 0, 0, time.UTC)},
+		{name: "Past year 9999", rule: "FREQ=YEARLY", current: time.Date(9999, 6, 1, 9, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mustParse(t, tt.rule).Next(tt.current)
+			if ok {
+				t.Errorf("got %s; want no occurrence", got)
+			}
+		})
+	}
+}
+
+func TestNextCountExhausted(t *testing.T) {
+	r := mustParse(t, "FREQ=WEEKLY;COUNT=3")
+	current := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	var occurrences []time.Time
+	for {
+		next, ok := r.Next(current)
+		if !ok {
+			break
+		}
+		occurrences = append(occurrences, next)
+		current, r = next, r.Rest()
+	}
+
+	// the current occurrence counts as the first of the three
+	want := []time.Time{
+		time.Date(2024, 5, 8, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 5, 15, 9, 0, 0, 0, time.UTC),
+	}
+	if len(occurrences) != len(want) {
+		t.Fatalf("got %d occurrences; want %d", len(occurrences), len(want))
+	}
+	for i := range want {
+		if !occurrences[i].Equal(want[i]) {
+			t.Errorf("occurrence %d: got %s; want %s", i, occurrences[i], want[i])
+		}
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		current time.Time
+		want    time.Time
+	}{
+		{
+			name:    "Spring forward",
+			rule:    "FREQ=DAILY",
+			current: time.Date(2024, 3, 9, 9, 0, 0, 0, loc),
+			want:    time.Date(2024, 3, 10, 9, 0, 0, 0, loc),
+		},
+		{
+			name:    "Fall back",
+			rule:    "FREQ=DAILY",
+			current: time.Date(2024, 11, 2, 9, 0, 0, 0, loc),
+			want:    time.Date(2024, 11, 3, 9, 0, 0, 0, loc),
+		},
+		{
+			name:    "Weekly across the change",
+			rule:    "FREQ=WEEKLY",
+			current: time.Date(2024, 3, 5, 23, 30, 0, 0, loc),
+			want:    time.Date(2024, 3, 12, 23, 30, 0, 0, loc),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mustParse(t, tt.rule).Next(tt.current)
+			if !ok {
+				t.Fatalf("got no occurrence; want %s", tt.want)
+			}
+			// the occurrence keeps its local time of day, not its
+			// distance in hours
+			if !got.Equal(tt.want) {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+			if hours := got.Sub(tt.current).Hours(); hours == 24 || hours == 7*24 {
+				t.Errorf("got %s, a whole number of days after %s across a time change", got, tt.current)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+// Package recurrence implements the subset of RFC 5545 recurrence rules
+// (RRULE) used by recurring todos, such as "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"
+// (every weekday) or "FREQ=MONTHLY;BYDAY=1MO" (the first Monday of the month).
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRule is wrapped by every error returned by Parse
+var ErrInvalidRule = errors.New("recurrence: invalid rule")
+
+// Frequency is the FREQ part of a rule
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+var frequencyNames = []string{"DAILY", "WEEKLY", "MONTHLY", "YEARLY"}
+
+// weekdays by their two letter RRULE names
+var weekdayNames = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// WeekdayNum is one entry of BYDAY, such as "MO" (every Monday, N is 0),
+// "1MO" (the first Monday) or "-1FR" (the last Friday) of the month or year
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+// Rule is a parsed recurrence rule
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	// number of occurrences left, including the current one, 0 for no limit
+	Count int
+	// last possible occurrence, zero for no limit
+	Until time.Time
+	// first day of the week, used by weekly rules with an interval
+	WeekStart time.Weekday
+}
+
+// Parse parses an RRULE value, with or without the "RRULE:" prefix.
+// DTSTART is not part of the rule: occurrences are computed from the
+// current one, see Next.
+func Parse(s string) (*Rule, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "RRULE:")
+	if s == "" {
+		return nil, invalid("the rule is empty")
+	}
+
+	r := &Rule{Freq: -1, Interval: 1, WeekStart: time.Monday}
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, invalid("%q is not a KEY=VALUE pair", part)
+		}
+		if seen[key] {
+			return nil, invalid("%s is given more than once", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			err = r.parseFreq(value)
+		case "INTERVAL":
+			r.Interval, err = parseInt(key, value, 1, 1000)
+		case "COUNT":
+			r.Count, err = parseInt(key, value, 1, 1000)
+		case "UNTIL":
+			r.Until, err = parseUntil(value)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseIntList(key, value, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseIntList(key, value, 12)
+			for _, m := range months {
+				if m < 0 {
+					return nil, invalid("BYMONTH must be between 1 and 12")
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "WKST":
+			day, ok := weekdayNames[value]
+			if !ok {
+				return nil, invalid("WKST must be a weekday such as MO")
+			}
+			r.WeekStart = day
+		default:
+			return nil, invalid("%s is not supported", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r, r.check()
+}
+
+// check validates the combination of the parts of a rule
+func (r *Rule) check() error {
+	if r.Freq < 0 {
+		return invalid("FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return invalid("COUNT and UNTIL cannot be used together")
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return invalid("BYMONTHDAY cannot be used with FREQ=WEEKLY")
+	}
+	for _, wd := range r.ByDay {
+		if wd.N == 0 {
+			continue
+		}
+		if r.Freq != Monthly && r.Freq != Yearly {
+			return invalid("numbered BYDAY values need FREQ=MONTHLY or FREQ=YEARLY")
+		}
+		if r.Freq == Monthly && (wd.N < -5 || wd.N > 5) {
+			return invalid("numbered BYDAY values must be between -5 and 5 in a month")
+		}
+	}
+	return nil
+}
+
+func (r *Rule) parseFreq(value string) error {
+	for i, name := range frequencyNames {
+		if name == value {
+			r.Freq = Frequency(i)
+			return nil
+		}
+	}
+	return invalid("FREQ must be one of DAILY, WEEKLY, MONTHLY or YEARLY")
+}
+
+func parseInt(key, value string, min, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, invalid("%s must be between %d and %d", key, min, max)
+	}
+	return n, nil
+}
+
+// parseIntList parses a list of non-zero numbers between -max and max
+func parseIntList(key, value string, max int) ([]int, error) {
+	var list []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil || n == 0 || n < -max || n > max {
+			return nil, invalid("%s values must be between 1 and %d, or -%d and -1", key, max, max)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// parseUntil accepts a date, a UTC date-time or a floating date-time, which
+// is read as UTC. A date includes the whole day.
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, invalid("UNTIL must be a date such as 20301231 or a date-time such as 20301231T235959Z")
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var list []WeekdayNum
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, invalid("%q is not a BYDAY value", item)
+		}
+		day, ok := weekdayNames[item[len(item)-2:]]
+		if !ok {
+			return nil, invalid("%q is not a BYDAY value", item)
+		}
+
+		wd := WeekdayNum{Day: day}
+		if prefix := item[:len(item)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, invalid("%q is not a BYDAY value", item)
+			}
+			wd.N = n
+		}
+		list = append(list, wd)
+	}
+	return list, nil
+}
+
+func invalid(format string, a ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidRule, fmt.Sprintf(format, a...))
+}
+
+// String returns the rule in its canonical RRULE form, without prefix
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + frequencyNames[r.Freq]}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			days = append(days, wd.String())
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]int, 0, len(r.ByMonth))
+		for _, m := range r.ByMonth {
+			months = append(months, int(m))
+		}
+		parts = append(parts, "BYMONTH="+joinInts(months))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayName(r.WeekStart))
+	}
+	return strings.Join(parts, ";")
+}
+
+// String returns the BYDAY form of wd, such as "MO" or "-1FR"
+func (wd WeekdayNum) String() string {
+	if wd.N == 0 {
+		return weekdayName(wd.Day)
+	}
+	return strconv.Itoa(wd.N) + weekdayName(wd.Day)
+}
+
+func weekdayName(day time.Weekday) string {
+	for name, d := range weekdayNames {
+		if d == day {
+			return name
+		}
+	}
+	return ""
+}
+
+func joinInts(list []int) string {
+	s := make([]string, 0, len(list))
+	for _, n := range list {
+		s = append(s, strconv.Itoa(n))
+	}
+	return strings.Join(s, ",")
+}
+
+// Rest returns the rule of the occurrences following the current one,
+// which only differs from r by its count
+func (r *Rule) Rest() *Rule {
+	rest := *r
+	if rest.Count > 0 {
+		rest.Count--
+	}
+	return &rest
+}
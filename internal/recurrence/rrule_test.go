@@ -0,0 +1,96 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		want string
+	}{
+		{name: "Prefix and lower case", rule: "rrule:freq=weekly;byday=mo,we", want: "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{name: "Default interval", rule: "FREQ=DAILY;INTERVAL=1", want: "FREQ=DAILY"},
+		{name: "Numbered weekday", rule: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", want: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3"},
+		{name: "Month days", rule: "FREQ=MONTHLY;BYMONTHDAY=15,-1", want: "FREQ=MONTHLY;BYMONTHDAY=15,-1"},
+		{name: "Until date", rule: "FREQ=YEARLY;UNTIL=20301231", want: "FREQ=YEARLY;UNTIL=20301231T235959Z"},
+		{name: "Until date-time", rule: "FREQ=DAILY;UNTIL=20301231T120000Z", want: "FREQ=DAILY;UNTIL=20301231T120000Z"},
+		{name: "Week start", rule: "FREQ=WEEKLY;WKST=SU;INTERVAL=2", want: "FREQ=WEEKLY;INTERVAL=2;WKST=SU"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %s", tt.rule, err)
+			}
+			if got := r.String(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+	}{
+		{name: "Empty", rule: ""},
+		{name: "Prefix only", rule: "RRULE:"},
+		{name: "Not a pair", rule: "FREQ"},
+		{name: "Missing FREQ", rule: "INTERVAL=2"},
+		{name: "Unknown FREQ", rule: "FREQ=HOURLY"},
+		{name: "Repeated key", rule: "FREQ=DAILY;FREQ=WEEKLY"},
+		{name: "Unsupported key", rule: "FREQ=MONTHLY;BYSETPOS=1"},
+		{name: "Zero interval", rule: "FREQ=DAILY;INTERVAL=0"},
+		{name: "Zero count", rule: "FREQ=DAILY;COUNT=0"},
+		{name: "Count and until", rule: "FREQ=DAILY;COUNT=2;UNTIL=20300101"},
+		{name: "Bad until", rule: "FREQ=DAILY;UNTIL=tomorrow"},
+		{name: "Bad weekday", rule: "FREQ=WEEKLY;BYDAY=XX"},
+		{name: "Numbered weekday in a week", rule: "FREQ=WEEKLY;BYDAY=1MO"},
+		{name: "Sixth weekday of a month", rule: "FREQ=MONTHLY;BYDAY=6MO"},
+		{name: "Month day in a week", rule: "FREQ=WEEKLY;BYMONTHDAY=1"},
+		{name: "Month day out of range", rule: "FREQ=MONTHLY;BYMONTHDAY=32"},
+		{name: "Month out of range", rule: "FREQ=YEARLY;BYMONTH=13"},
+		{name: "Negative month", rule: "FREQ=YEARLY;BYMONTH=-1"},
+		{name: "Bad week start", rule: "FREQ=WEEKLY;WKST=XX"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.rule)
+			if !errors.Is(err, ErrInvalidRule) {
+				t.Errorf("Parse(%q): got error %v; want %v", tt.rule, err, ErrInvalidRule)
+			}
+		})
+	}
+}
+
+func TestRest(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{rule: "FREQ=DAILY;COUNT=3", want: "FREQ=DAILY;COUNT=2"},
+		{rule: "FREQ=DAILY", want: "FREQ=DAILY"},
+		{rule: "FREQ=DAILY;UNTIL=20301231T120000Z", want: "FREQ=DAILY;UNTIL=20301231T120000Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %s", tt.rule, err)
+			}
+			if got := r.Rest().String(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+			if got := r.String(); got != tt.rule {
+				t.Errorf("the rule changed to %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore keeps contents as files below a directory of the local file
+// system, at <root>/<first two digits of the key>/<key>
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore returns a store keeping its contents below root, which is
+// created if it does not exist yet
+func NewLocalStore(root string) (*LocalStore, error) {
+	err := os.MkdirAll(filepath.Join(root, "tmp"), 0o750)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalStore{root: root}, nil
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.root, key[:2], key)
+}
+
+// Stage writes the content to a temporary file while computing its
+// checksum, Commit then moves the file to its final path
+func (s *LocalStore) Stage(r io.Reader, maxSize int64) (Staged, error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.root, "tmp"), "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	staged := &localStaged{store: s, tmp: tmp.Name()}
+	defer tmp.Close()
+
+	h := sha256.New()
+	// read one byte past the limit to tell a content of exactly
+	// maxSize bytes from a larger one
+	staged.size, err = io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, maxSize+1))
+	if err == nil && staged.size > maxSize {
+		err = ErrTooLarge
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = tmp.Close()
+	}
+	if err != nil {
+		staged.Discard()
+		return nil, err
+	}
+
+	staged.key = hex.EncodeToString(h.Sum(nil))
+	return staged, nil
+}
+
+// localStaged is a content staged in the tmp directory of a LocalStore
+type localStaged struct {
+	store *LocalStore
+	tmp   string
+	key   string
+	size  int64
+}
+
+func (l *localStaged) Key() string {
+	return l.key
+}
+
+func (l *localStaged) Size() int64 {
+	return l.size
+}
+
+// Commit moves the temporary file to its final path. Committing a content
+// which is already stored leaves the existing file in place.
+func (l *localStaged) Commit() error {
+	path := l.store.path(l.key)
+
+	_, err := os.Stat(path)
+	if err == nil {
+		return l.Discard()
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o750)
+	if err != nil {
+		return err
+	}
+	return os.Rename(l.tmp, path)
+}
+
+// Discard removes the temporary file, which is already gone once the
+// content has been committed
+func (l *localStaged) Discard() error {
+	err := os.Remove(l.tmp)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Open returns the content stored under key, verifying its checksum
+// as it is read
+func (s *LocalStore) Open(key string) (io.ReadCloser, error) {
+	if !ValidKey(key) {
+		return nil, ErrNotFound
+	}
+
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &verifyingReader{file: f, hash: sha256.New(), key: key}, nil
+}
+
+// Delete removes the content stored under key, deleting a missing
+// content is not an error
+func (s *LocalStore) Delete(key string) error {
+	if !ValidKey(key) {
+		return nil
+	}
+
+	err := os.Remove(s.path(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// verifyingReader hashes a file as it is read, and returns ErrChecksum
+// instead of io.EOF if the file does not match its key
+type verifyingReader struct {
+	file *os.File
+	hash hash.Hash
+	key  string
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.file.Read(p)
+	v.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(v.hash.Sum(nil)) != v.key {
+		return n, ErrChecksum
+	}
+	return n, err
+}
+
+func (v *verifyingReader) Close() error {
+	return v.file.Close()
+}
@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestStore returns a LocalStore kept in a temporary directory
+func newTestStore(t *testing.T) *LocalStore {
+	t.Helper()
+	s, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %s", err)
+	}
+	return s
+}
+
+// put stages and commits content, and returns its key
+func put(t *testing.T, s *LocalStore, content string) string {
+	t.Helper()
+	staged, err := s.Stage(strings.NewReader(content), 1024)
+	if err != nil {
+		t.Fatalf("Stage: %s", err)
+	}
+	defer staged.Discard()
+
+	err = staged.Commit()
+	if err != nil {
+		t.Fatalf("Commit: %s", err)
+	}
+	return staged.Key()
+}
+
+// read returns the content stored under key
+func read(t *testing.T, s *LocalStore, key string) (string, error) {
+	t.Helper()
+	r, err := s.Open(key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	return string(b), err
+}
+
+// tmpFiles returns the number of files left in the tmp directory of s
+func tmpFiles(t *testing.T, s *LocalStore) int {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(s.root, "tmp"))
+	if err != nil {
+		t.Fatalf("ReadDir: %s", err)
+	}
+	return len(entries)
+}
+
+func TestLocalStoreStage(t *testing.T) {
+	s := newTestStore(t)
+	content := "hello, world"
+	sum := sha256.Sum256([]byte(content))
+
+	staged, err := s.Stage(strings.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("Stage: %s", err)
+	}
+	if want := hex.EncodeToString(sum[:]); staged.Key() != want {
+		t.Errorf("got key %q; want %q", staged.Key(), want)
+	}
+	if staged.Size() != int64(len(content)) {
+		t.Errorf("got size %d; want %d", staged.Size(), len(content))
+	}
+	if !ValidKey(staged.Key()) {
+		t.Errorf("key %q is not valid", staged.Key())
+	}
+
+	// the content cannot be read before it is committed
+	_, err = read(t, s, staged.Key())
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v before Commit; want %v", err, ErrNotFound)
+	}
+
+	err = staged.Commit()
+	if err != nil {
+		t.Fatalf("Commit: %s", err)
+	}
+	got, err := read(t, s, staged.Key())
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+	if got != content {
+		t.Errorf("got %q; want %q", got, content)
+	}
+
+	// discarding a committed content leaves it in place
+	err = staged.Discard()
+	if err != nil {
+		t.Fatalf("Discard: %s", err)
+	}
+	if _, err = read(t, s, staged.Key()); err != nil {
+		t.Errorf("read after Discard: %s", err)
+	}
+	if n := tmpFiles(t, s); n != 0 {
+		t.Errorf("%d temporary files left", n)
+	}
+}
+
+func TestLocalStoreStageTooLarge(t *testing.T) {
+	s := newTestStore(t)
+
+	_, err := s.Stage(strings.NewReader("0123456789"), 9)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("got error %v; want %v", err, ErrTooLarge)
+	}
+	if n := tmpFiles(t, s); n != 0 {
+		t.Errorf("%d temporary files left", n)
+	}
+}
+
+func TestLocalStoreDiscard(t *testing.T) {
+	s := newTestStore(t)
+
+	staged, err := s.Stage(strings.NewReader("draft"), 1024)
+	if err != nil {
+		t.Fatalf("Stage: %s", err)
+	}
+	err = staged.Discard()
+	if err != nil {
+		t.Fatalf("Discard: %s", err)
+	}
+
+	if _, err = read(t, s, staged.Key()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v; want %v", err, ErrNotFound)
+	}
+	if n := tmpFiles(t, s); n != 0 {
+		t.Errorf("%d temporary files left", n)
+	}
+}
+
+func TestLocalStoreDedup(t *testing.T) {
+	s := newTestStore(t)
+
+	first := put(t, s, "same bytes")
+	info, err := os.Stat(s.path(first))
+	if err != nil {
+		t.Fatalf("Stat: %s", err)
+	}
+
+	second := put(t, s, "same bytes")
+	if second != first {
+		t.Fatalf("got key %q for the same content; want %q", second, first)
+	}
+
+	// the stored file is kept rather than replaced
+	again, err := os.Stat(s.path(first))
+	if err != nil {
+		t.Fatalf("Stat: %s", err)
+	}
+	if !os.SameFile(info, again) {
+		t.Error("the stored file was replaced")
+	}
+	if n := tmpFiles(t, s); n != 0 {
+		t.Errorf("%d temporary files left", n)
+	}
+
+	if other := put(t, s, "other bytes"); other == first {
+		t.Errorf("got the same key %q for different contents", other)
+	}
+}
+
+func TestLocalStoreOpenChecksum(t *testing.T) {
+	s := newTestStore(t)
+	key := put(t, s, "original content")
+
+	// alter the stored file behind the store's back
+	err := os.WriteFile(s.path(key), []byte("altered content!"), 0o640)
+	if err != nil {
+		t.Fatalf("WriteFile: %s", err)
+	}
+
+	_, err = read(t, s, key)
+	if !errors.Is(err, ErrChecksum) {
+		t.Errorf("got error %v; want %v", err, ErrChecksum)
+	}
+}
+
+func TestLocalStoreOpenNotFound(t *testing.T) {
+	s := newTestStore(t)
+	missing := strings.Repeat("ab", 32)
+
+	for _, key := range []string{missing, "", "../../etc/passwd", strings.ToUpper(missing)} {
+		_, err := s.Open(key)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Open(%q): got error %v; want %v", key, err, ErrNotFound)
+		}
+	}
+}
+
+func TestLocalStoreDelete(t *testing.T) {
+	s := newTestStore(t)
+	key := put(t, s, "to be deleted")
+
+	err := s.Delete(key)
+	if err != nil {
+		t.Fatalf("Delete: %s", err)
+	}
+	if _, err = read(t, s, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v after Delete; want %v", err, ErrNotFound)
+	}
+
+	// deleting a missing content or an invalid key is not an error
+	for _, key := range []string{key, "not a key"} {
+		if err = s.Delete(key); err != nil {
+			t.Errorf("Delete(%q): %s", key, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Package storage keeps the content of file attachments. Contents are
+// addressed by their SHA-256 checksum, so that a file uploaded several times
+// is stored once and any corruption is detected when it is read back.
+package storage
+
+import (
+	"errors"
+	"io"
+	"regexp"
+)
+
+// ErrNotFound is returned when no content is stored under a key
+var ErrNotFound = errors.New("storage: content not found")
+
+// ErrTooLarge is returned by Stage when the content is larger than the limit
+var ErrTooLarge = errors.New("storage: content too large")
+
+// ErrChecksum is returned while reading a content which no longer matches
+// its key
+var ErrChecksum = errors.New("storage: checksum mismatch")
+
+// Store is implemented by the places where contents are kept
+type Store interface {
+	// Stage writes the content read from r, up to maxSize bytes, aside.
+	// It is only stored under its key once committed.
+	Stage(r io.Reader, maxSize int64) (Staged, error)
+	// Open returns a reader over the content stored under key, which fails
+	// with ErrChecksum at the end of the content if it was altered
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the content stored under key, if any
+	Delete(key string) error
+}
+
+// Staged is a content written aside by Stage
+type Staged interface {
+	// Key returns the hex-encoded SHA-256 checksum of the content
+	Key() string
+	// Size returns the size of the content in bytes
+	Size() int64
+	// Commit stores the content under its key, an identical content
+	// already stored is left in place
+	Commit() error
+	// Discard removes the content unless it was committed
+	Discard() error
+}
+
+var keyRX = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// ValidKey reports whether key is a SHA-256 checksum as returned by Staged.Key
+func ValidKey(key string) bool {
+	return keyRX.MatchString(key)
+}
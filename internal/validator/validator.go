@@ -3,6 +3,7 @@ package validator
 import (
 	"regexp"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -25,6 +26,9 @@ func (v *Validator) AddNonFieldError(message string) {
 // W3C and Web Hypertext Application Technology Working Group recommended email checking pattern
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// hexadecimal "#rrggbb" color, as used by tags
+var ColorRX = regexp.MustCompile("^#[0-9a-fA-F]{6}$")
+
 // returns true if a value contains at least n characters
 func MinChars(value string, n int) bool {
 	return utf8.RuneCountInString(value) >= n
@@ -75,3 +79,38 @@ func PermittedInt(value int, permittedValues ...int) bool {
 	}
 	return false
 }
+
+// IntBetween() returns true if a value falls within [min, max].
+func IntBetween(value, min, max int) bool {
+	return value >= min && value <= max
+}
+
+// PermittedString() returns true if a value is in a list of permitted strings.
+func PermittedString(value string, permittedValues ...string) bool {
+	for i := range permittedValues {
+		if value == permittedValues[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// NotAfter() returns true if a time is not later than another time.
+func NotAfter(value, limit time.Time) bool {
+	return !value.After(limit)
+}
+
+// TimeBetween() returns true if a time falls within [min, max].
+func TimeBetween(value, min, max time.Time) bool {
+	return !value.Before(min) && !value.After(max)
+}
+
+// ValidTimezone() returns true if a value is an IANA time zone name,
+// such as "Europe/Berlin" or "UTC".
+func ValidTimezone(value string) bool {
+	if value == "" || value == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(value)
+	return err == nil
+}
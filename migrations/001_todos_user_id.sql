@@ -0,0 +1,7 @@
+-- Every todo is owned by the user who created it.
+-- Existing rows have no owner and will not be visible to anyone until
+-- their user_id is set, e.g.:
+--   UPDATE todos SET user_id = '<user uuid>' WHERE user_id IS NULL;
+ALTER TABLE todos ADD COLUMN user_id CHAR(36) NULL AFTER id;
+
+CREATE INDEX idx_todos_user_created ON todos (user_id, created);
@@ -0,0 +1,7 @@
+-- Indexes backing the keyset pagination of the todo list: every sort order is
+-- (user_id, <sort column>, id), optionally narrowed down by status.
+CREATE INDEX idx_todos_user_created_id ON todos (user_id, created, id);
+CREATE INDEX idx_todos_user_status_created_id ON todos (user_id, status, created, id);
+CREATE INDEX idx_todos_user_body_id ON todos (user_id, body(191), id);
+
+DROP INDEX idx_todos_user_created ON todos;
@@ -0,0 +1,9 @@
+-- Optional start and due dates of a todo, stored in UTC.
+ALTER TABLE todos
+    ADD COLUMN start_at DATETIME NULL,
+    ADD COLUMN due_at DATETIME NULL;
+
+CREATE INDEX idx_todos_user_due ON todos (user_id, due_at);
+
+-- The time zone "today" and "this week" are computed in.
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
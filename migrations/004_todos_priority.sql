@@ -0,0 +1,7 @@
+-- Priority (0 none, 1 low, 2 medium, 3 high, 4 urgent) and importance of a
+-- todo, which together place it in the Eisenhower matrix.
+ALTER TABLE todos
+    ADD COLUMN priority TINYINT NOT NULL DEFAULT 0,
+    ADD COLUMN important BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX idx_todos_user_status_priority ON todos (user_id, status, priority);
@@ -0,0 +1,18 @@
+-- Named, colored labels owned by a user and attached to any number of todos.
+CREATE TABLE tags (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    color CHAR(7) NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT tags_uc_user_name UNIQUE (user_id, name)
+);
+
+CREATE TABLE todo_tags (
+    todo_id CHAR(36) NOT NULL,
+    tag_id CHAR(36) NOT NULL,
+    PRIMARY KEY (todo_id, tag_id),
+    INDEX idx_todo_tags_tag (tag_id),
+    CONSTRAINT fk_todo_tags_todo FOREIGN KEY (todo_id) REFERENCES todos (id) ON DELETE CASCADE,
+    CONSTRAINT fk_todo_tags_tag FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
@@ -0,0 +1,27 @@
+-- Named lists (projects) grouping the todos of a user. The inbox column is
+-- TRUE for the default list and NULL otherwise, so that the unique key only
+-- allows one inbox per user.
+CREATE TABLE lists (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    color VARCHAR(7) NOT NULL DEFAULT '',
+    icon VARCHAR(50) NOT NULL DEFAULT '',
+    archived BOOLEAN NOT NULL DEFAULT false,
+    inbox BOOLEAN NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT lists_uc_user_name UNIQUE (user_id, name),
+    CONSTRAINT lists_uc_user_inbox UNIQUE (user_id, inbox)
+);
+
+ALTER TABLE todos ADD COLUMN list_id CHAR(36) NULL AFTER user_id;
+CREATE INDEX idx_todos_user_list ON todos (user_id, list_id);
+
+-- Give every existing user an inbox holding all of their todos.
+INSERT INTO lists (id, user_id, name, inbox, created)
+SELECT UUID(), uuid, 'Inbox', true, UTC_TIMESTAMP() FROM users;
+
+UPDATE todos
+JOIN lists ON lists.user_id = todos.user_id AND lists.inbox IS NOT NULL
+SET todos.list_id = lists.id
+WHERE todos.list_id IS NULL;
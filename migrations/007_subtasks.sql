@@ -0,0 +1,7 @@
+-- Subtasks: a todo may be nested under another todo of the same user, at any
+-- depth. Deleting a todo deletes its subtasks.
+ALTER TABLE todos
+    ADD COLUMN parent_id CHAR(36) NULL AFTER list_id,
+    ADD CONSTRAINT fk_todos_parent FOREIGN KEY (parent_id) REFERENCES todos (id) ON DELETE CASCADE;
+
+CREATE INDEX idx_todos_parent_status ON todos (parent_id, status);
@@ -0,0 +1,3 @@
+-- Recurring todos: an RFC 5545 recurrence rule, such as
+-- FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR, or '' for todos which do not recur.
+ALTER TABLE todos ADD COLUMN rrule VARCHAR(255) NOT NULL DEFAULT '' AFTER important;
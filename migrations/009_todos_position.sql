@@ -0,0 +1,14 @@
+-- Manual order of the todos of a user: position holds a fractional index
+-- (see internal/rank), compared byte by byte. Existing todos keep their
+-- newest first order.
+ALTER TABLE todos
+    ADD COLUMN position VARCHAR(255) CHARACTER SET ascii COLLATE ascii_bin NOT NULL DEFAULT '' AFTER rrule;
+
+UPDATE todos
+JOIN (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created DESC, id) AS n
+    FROM todos
+) ordered ON ordered.id = todos.id
+SET todos.position = CONCAT(LPAD(ordered.n, 9, '0'), 'V');
+
+CREATE INDEX idx_todos_user_position ON todos (user_id, position);
@@ -0,0 +1,5 @@
+-- Soft delete: deleted todos are kept in the trash, with the time they were
+-- deleted, until they are restored or purged.
+ALTER TABLE todos ADD COLUMN deleted_at DATETIME NULL AFTER position;
+CREATE INDEX idx_todos_user_deleted ON todos (user_id, deleted_at);
+CREATE INDEX idx_todos_deleted ON todos (deleted_at);
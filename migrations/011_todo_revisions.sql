@@ -0,0 +1,14 @@
+-- History of the changes made to each todo. The state of the todo before and
+-- after each change is stored as JSON (see models.TodoState); before_state is
+-- NULL for the creation of the todo.
+CREATE TABLE todo_revisions (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    todo_id CHAR(36) NOT NULL,
+    actor_id CHAR(36) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    before_state JSON NULL,
+    after_state JSON NOT NULL,
+    created DATETIME(6) NOT NULL,
+    INDEX idx_todo_revisions_todo_created (todo_id, created),
+    CONSTRAINT fk_todo_revisions_todo FOREIGN KEY (todo_id) REFERENCES todos (id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+-- Long-form notes in CommonMark, stored raw and rendered on demand.
+ALTER TABLE todos ADD COLUMN notes TEXT NOT NULL AFTER body;
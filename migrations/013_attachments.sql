@@ -0,0 +1,15 @@
+-- Files uploaded to todos. The content is kept outside of the database, in
+-- the attachment store, under its SHA-256 checksum: identical files share
+-- one content, which is removed once no attachment references it anymore.
+CREATE TABLE attachments (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    todo_id CHAR(36) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    created DATETIME NOT NULL,
+    INDEX idx_attachments_todo_created (todo_id, created),
+    INDEX idx_attachments_checksum (checksum),
+    CONSTRAINT fk_attachments_todo FOREIGN KEY (todo_id) REFERENCES todos (id) ON DELETE CASCADE
+);
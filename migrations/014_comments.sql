@@ -0,0 +1,12 @@
+-- Discussion threads on todos. edited_at stays NULL until the author
+-- changes the body of the comment.
+CREATE TABLE comments (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    todo_id CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    body TEXT NOT NULL,
+    created DATETIME(6) NOT NULL,
+    edited_at DATETIME(6) NULL,
+    INDEX idx_comments_todo_created (todo_id, created),
+    CONSTRAINT fk_comments_todo FOREIGN KEY (todo_id) REFERENCES todos (id) ON DELETE CASCADE
+);
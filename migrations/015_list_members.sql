@@ -0,0 +1,18 @@
+-- Members of each list, with their role: viewer, editor or owner. The
+-- creator of a list is one of its owners, so that users reach their own
+-- lists and the lists shared with them the same way.
+CREATE TABLE list_members (
+    list_id CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    role VARCHAR(10) NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (list_id, user_id),
+    INDEX idx_list_members_user_role (user_id, role),
+    CONSTRAINT fk_list_members_list FOREIGN KEY (list_id) REFERENCES lists (id) ON DELETE CASCADE
+);
+
+INSERT INTO list_members (list_id, user_id, role, created)
+SELECT id, user_id, 'owner', created FROM lists;
+
+-- todos are now found through their list rather than their creator
+CREATE INDEX idx_todos_list ON todos (list_id);
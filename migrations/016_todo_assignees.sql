@@ -0,0 +1,4 @@
+-- The member of its list a todo is handed to. Assignees can change the
+-- status of their todos whatever their role in the list.
+ALTER TABLE todos ADD COLUMN assignee_id CHAR(36) NULL AFTER position;
+CREATE INDEX idx_todos_assignee ON todos (assignee_id);
@@ -0,0 +1,54 @@
+-- Workflows: the ordered states the todos of the lists created by a user go
+-- through, and the moves allowed between them. The first state is given to
+-- new todos, the last one to done todos.
+CREATE TABLE workflow_states (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    position INT NOT NULL,
+    created DATETIME NOT NULL,
+    INDEX idx_workflow_states_user_position (user_id, position),
+    CONSTRAINT workflow_states_uc_user_name UNIQUE (user_id, name)
+);
+
+CREATE TABLE workflow_transitions (
+    from_state_id CHAR(36) NOT NULL,
+    to_state_id CHAR(36) NOT NULL,
+    PRIMARY KEY (from_state_id, to_state_id),
+    CONSTRAINT fk_workflow_transitions_from FOREIGN KEY (from_state_id) REFERENCES workflow_states (id) ON DELETE CASCADE,
+    CONSTRAINT fk_workflow_transitions_to FOREIGN KEY (to_state_id) REFERENCES workflow_states (id) ON DELETE CASCADE
+);
+
+ALTER TABLE todos ADD COLUMN state_id CHAR(36) NULL AFTER status;
+CREATE INDEX idx_todos_state ON todos (state_id);
+
+-- Give every existing user the default workflow, see models.DefaultStates.
+INSERT INTO workflow_states (id, user_id, name, position, created)
+SELECT UUID(), users.uuid, d.name, d.position, UTC_TIMESTAMP() FROM users
+CROSS JOIN (
+    SELECT 'backlog' AS name, 1 AS position
+    UNION ALL SELECT 'todo', 2
+    UNION ALL SELECT 'in progress', 3
+    UNION ALL SELECT 'blocked', 4
+    UNION ALL SELECT 'done', 5
+) d;
+
+INSERT INTO workflow_transitions (from_state_id, to_state_id)
+SELECT f.id, t.id FROM workflow_states f
+JOIN workflow_states t ON t.user_id = f.user_id
+JOIN (
+    SELECT 'backlog' AS from_name, 'todo' AS to_name
+    UNION ALL SELECT 'todo', 'backlog'
+    UNION ALL SELECT 'todo', 'in progress'
+    UNION ALL SELECT 'in progress', 'todo'
+    UNION ALL SELECT 'in progress', 'blocked'
+    UNION ALL SELECT 'in progress', 'done'
+    UNION ALL SELECT 'blocked', 'in progress'
+    UNION ALL SELECT 'done', 'todo'
+) d ON d.from_name = f.name AND d.to_name = t.name;
+
+-- Open todos start in the backlog, done todos are done.
+UPDATE todos
+JOIN lists ON lists.id = todos.list_id
+JOIN workflow_states ws ON ws.user_id = lists.user_id AND ws.name = IF(todos.status, 'done', 'backlog')
+SET todos.state_id = ws.id;
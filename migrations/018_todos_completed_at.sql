@@ -0,0 +1,13 @@
+-- The time a todo was completed, NULL while it is open.
+ALTER TABLE todos ADD COLUMN completed_at DATETIME NULL AFTER state_id;
+CREATE INDEX idx_todos_completed ON todos (completed_at);
+
+-- Done todos were completed by the last revision which marked them as done,
+-- or at their creation if their history does not say.
+UPDATE todos SET completed_at = COALESCE(
+    (SELECT MAX(r.created) FROM todo_revisions r
+     WHERE r.todo_id = todos.id
+     AND JSON_EXTRACT(r.after_state, '$.status') = CAST('true' AS JSON)
+     AND (r.before_state IS NULL OR JSON_EXTRACT(r.before_state, '$.status') = CAST('false' AS JSON))),
+    created)
+WHERE status = true;
@@ -0,0 +1,16 @@
+-- Time tracked by users on todos. stopped_at stays NULL while the timer of
+-- the entry is running; running_user_id only holds the user of a running
+-- timer, so that a user cannot have two timers running at once.
+CREATE TABLE time_entries (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    todo_id CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    started_at DATETIME(6) NOT NULL,
+    stopped_at DATETIME(6) NULL,
+    running_user_id CHAR(36) AS (IF(stopped_at IS NULL, user_id, NULL)) STORED,
+    created DATETIME(6) NOT NULL,
+    INDEX idx_time_entries_todo_started (todo_id, started_at),
+    INDEX idx_time_entries_user_started (user_id, started_at),
+    CONSTRAINT time_entries_uc_running UNIQUE (running_user_id),
+    CONSTRAINT fk_time_entries_todo FOREIGN KEY (todo_id) REFERENCES todos (id) ON DELETE CASCADE
+);
@@ -0,0 +1,11 @@
+-- The unit users estimate todos in, minutes or story points.
+ALTER TABLE users ADD COLUMN estimate_unit VARCHAR(10) NOT NULL DEFAULT 'minutes' AFTER timezone;
+
+-- The estimated effort of a todo, in the unit of the member who estimated
+-- it when they did. The three columns are NULL for todos without an
+-- estimate.
+ALTER TABLE todos
+    ADD COLUMN estimate INT NULL AFTER completed_at,
+    ADD COLUMN estimate_unit VARCHAR(10) NULL AFTER estimate,
+    ADD COLUMN estimated_by CHAR(36) NULL AFTER estimate_unit;
+CREATE INDEX idx_todos_estimated_by ON todos (estimated_by);
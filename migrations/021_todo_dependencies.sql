@@ -0,0 +1,11 @@
+-- Dependencies between todos: todo_id cannot be completed while blocker_id
+-- is open. The graph is kept acyclic by the application.
+CREATE TABLE todo_dependencies (
+    todo_id CHAR(36) NOT NULL,
+    blocker_id CHAR(36) NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (todo_id, blocker_id),
+    INDEX idx_todo_dependencies_blocker (blocker_id),
+    CONSTRAINT fk_todo_dependencies_todo FOREIGN KEY (todo_id) REFERENCES todos (id) ON DELETE CASCADE,
+    CONSTRAINT fk_todo_dependencies_blocker FOREIGN KEY (blocker_id) REFERENCES todos (id) ON DELETE CASCADE
+);
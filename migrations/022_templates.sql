@@ -0,0 +1,25 @@
+-- Reusable checklists owned by a user. Each item is a todo to create when
+-- the template is instantiated, its dates given as offsets in seconds from
+-- the time the template is instantiated for, and parent_position pointing
+-- to the item it is a subtask of.
+CREATE TABLE templates (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    user_id CHAR(36) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT templates_uc_user_name UNIQUE (user_id, name)
+);
+
+CREATE TABLE template_items (
+    template_id CHAR(36) NOT NULL,
+    position INT NOT NULL,
+    parent_position INT NULL,
+    body VARCHAR(200) NOT NULL,
+    notes TEXT NOT NULL,
+    priority TINYINT NOT NULL DEFAULT 0,
+    important BOOLEAN NOT NULL DEFAULT false,
+    start_offset BIGINT NULL,
+    due_offset BIGINT NULL,
+    PRIMARY KEY (template_id, position),
+    CONSTRAINT fk_template_items_template FOREIGN KEY (template_id) REFERENCES templates (id) ON DELETE CASCADE
+);
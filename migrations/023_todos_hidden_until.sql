@@ -0,0 +1,3 @@
+-- Snoozed todos are left out of the default lists until hidden_until has
+-- passed. NULL for todos which are not snoozed.
+ALTER TABLE todos ADD COLUMN hidden_until DATETIME NULL AFTER due_at;
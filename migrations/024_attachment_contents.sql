@@ -0,0 +1,8 @@
+-- One row per content of the attachment store. Uploads and removals of a
+-- content lock its row, so that a content is never removed while an
+-- identical file is being attached.
+CREATE TABLE attachment_contents (
+    checksum CHAR(64) NOT NULL PRIMARY KEY
+);
+
+INSERT INTO attachment_contents (checksum) SELECT DISTINCT checksum FROM attachments;
@@ -0,0 +1,3 @@
+-- Todos created with an estimate were missing the member who gave it: their
+-- creator is the one who did.
+UPDATE todos SET estimated_by = user_id WHERE estimate IS NOT NULL AND estimated_by IS NULL;